@@ -16,8 +16,29 @@ func main() {
 	}
 	flag.StringVar(&cfg.HostPort, "server-host-port", cfg.HostPort, "Server host and port")
 	flag.IntVar(&cfg.CacheSize, "cache-size", cfg.CacheSize, "Cache size")
+	flag.Int64Var(&cfg.CacheMaxBytes, "cache-max-bytes", cfg.CacheMaxBytes, "Cache size in bytes, replaces the entry count limit when positive")
+	flag.IntVar(&cfg.CacheShards, "cache-shards", cfg.CacheShards, "Number of independently locked cache shards")
+	flag.StringVar(&cfg.EvictionPolicy, "eviction-policy", cfg.EvictionPolicy, "Eviction policy: lru, lfu, sieve, 2q or arc")
+	flag.BoolVar(&cfg.CacheAdmission, "cache-admission", cfg.CacheAdmission, "Enable TinyLFU admission filter")
 	flag.DurationVar(&cfg.DefaultTTL, "default-cache-ttl", cfg.DefaultTTL, "Default cache TTL")
 	flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Log level")
+	flag.DurationVar(&cfg.ExpireInterval, "cache-expire-interval", cfg.ExpireInterval, "Background TTL expiration interval, 0 disables it")
+	flag.IntVar(&cfg.ExpireSamples, "cache-expire-samples", cfg.ExpireSamples, "Number of keys sampled per background expiration round")
+	flag.Float64Var(&cfg.ExpireThreshold, "cache-expire-threshold", cfg.ExpireThreshold, "Expired ratio above which a background expiration round is repeated")
+	flag.DurationVar(&cfg.ExpireWheelTick, "cache-expire-wheel-tick", cfg.ExpireWheelTick, "Timing wheel TTL expiration precision, 0 disables it")
+	flag.StringVar(&cfg.StoreDir, "store-dir", cfg.StoreDir, "Directory of a file store backing the cache, empty disables it")
+	flag.BoolVar(&cfg.StoreWriteBehind, "store-write-behind", cfg.StoreWriteBehind, "Queue writes to the store instead of writing through")
+	flag.DurationVar(&cfg.StoreFlushInterval, "store-flush-interval", cfg.StoreFlushInterval, "Interval of write-behind flushes to the store")
+	flag.Float64Var(&cfg.RefreshAhead, "cache-refresh-ahead", cfg.RefreshAhead, "Last fraction of the TTL in which reads reload entries from the store, 0 disables it")
+	flag.DurationVar(&cfg.StaleGrace, "cache-stale-grace", cfg.StaleGrace, "Time expired entries are served while they are reloaded from the store, 0 disables it")
+	flag.StringVar(&cfg.SnapshotPath, "snapshot-path", cfg.SnapshotPath, "File the cache is restored from at startup and saved to on shutdown, empty disables it")
+	flag.DurationVar(&cfg.SnapshotInterval, "snapshot-interval", cfg.SnapshotInterval, "Interval of periodic snapshots, 0 disables them")
+	flag.StringVar(&cfg.AOFPath, "aof-path", cfg.AOFPath, "Append-only log the cache is rebuilt from at startup, empty disables it")
+	flag.StringVar(&cfg.AOFFsync, "aof-fsync", cfg.AOFFsync, "Append-only log fsync policy: always, everysec or never")
+	flag.Int64Var(&cfg.AOFRewriteSize, "aof-rewrite-size", cfg.AOFRewriteSize, "Append-only log size in bytes that triggers a compaction")
+	flag.StringVar(&cfg.RESPHostPort, "resp-host-port", cfg.RESPHostPort, "Host and port serving Redis clients, empty disables it")
+	flag.StringVar(&cfg.MemcacheHostPort, "memcache-host-port", cfg.MemcacheHostPort, "Host and port serving memcached clients, empty disables it")
+	flag.StringVar(&cfg.GRPCHostPort, "grpc-host-port", cfg.GRPCHostPort, "Host and port serving gRPC clients, empty disables it")
 	flag.Parse()
 
 	srv, err := srv.New(cfg)
@@ -0,0 +1,431 @@
+// Package aof persists the writes made to a cache in an append-only log and rebuilds the cache from it.
+//
+// The log is a file of JSON records, one per line, each being a stored key with its value and
+// absolute expiration time in Unix nanoseconds, a read key, a removed key or the removal of every key.
+// Reads are coalesced and written once a second, so that replaying restores the recency order
+// to within about a second.
+// When the log has grown past a threshold it is rewritten in the background from the contents of the cache,
+// so that it only holds one record per live key, ordered from the least to the most recently used one.
+package aof
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lru-cache/internal/cache"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fsync tells how often the log is flushed to stable storage.
+type Fsync string
+
+const (
+	// FsyncAlways syncs the log after every write, before the write returns.
+	FsyncAlways Fsync = "always"
+	// FsyncEverySec syncs the log once a second, losing at most about a second of writes on a crash.
+	FsyncEverySec Fsync = "everysec"
+	// FsyncNever hands the writes to the operating system once a second and lets it decide when to sync them.
+	FsyncNever Fsync = "never"
+)
+
+// ParseFsync returns the fsync policy with the given name.
+func ParseFsync(s string) (Fsync, error) {
+	switch f := Fsync(s); f {
+	case FsyncAlways, FsyncEverySec, FsyncNever:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: %q", errs.ErrUnknownFsync, s)
+}
+
+// Config configures an append-only log.
+type Config struct {
+	Path  string
+	Fsync Fsync
+	// RewriteSize is the size in bytes the log must reach, and at least double since it was last rewritten,
+	// before it is rewritten. 64MiB if not positive.
+	RewriteSize int64
+	// OnError is called with the errors of writes, syncs and rewrites, which cannot be returned to the writers.
+	OnError func(err error)
+}
+
+const (
+	opSet   = "set"
+	opGet   = "get"
+	opDel   = "del"
+	opFlush = "flush"
+)
+
+type record struct {
+	Op        string `json:"op"`
+	Key       string `json:"key,omitempty"`
+	Value     any    `json:"value,omitempty"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// Log is an append-only log of the writes made to a cache.
+// It implements lru.Journal, lru.AccessJournal and lru.SyncJournal.
+type Log struct {
+	cfg       Config
+	replaying atomic.Bool
+	// flushMu serializes flushes, which write and sync the file without holding mu.
+	flushMu sync.Mutex
+
+	mu   sync.Mutex
+	file *os.File
+	// buf holds the records not written to the file yet, spare the buffer flush swaps with it.
+	buf   []byte
+	spare []byte
+	// reads holds the keys read since the last flush with the position of their last read, counted by seq.
+	reads map[string]uint64
+	seq   uint64
+	// size is the size of the log including buffered writes, base its size after the last rewrite.
+	size int64
+	base int64
+	// rewriting is set while the log is rewritten, with the records written since it started kept in pending.
+	rewriting bool
+	pending   []byte
+
+	snapshot func() []lru.Entry[string, any]
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Open opens the log at cfg.Path, creating it if needed.
+func Open(cfg Config) (*Log, error) {
+	if cfg.Fsync == "" {
+		cfg.Fsync = FsyncEverySec
+	}
+	if cfg.RewriteSize <= 0 {
+		cfg.RewriteSize = 64 << 20
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Log{
+		cfg:   cfg,
+		file:  file,
+		reads: make(map[string]uint64),
+		size:  info.Size(),
+		base:  info.Size(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// Replay applies the records of the log to c, which must be empty and must not be used concurrently.
+// Writes made to c while replaying are not logged again. A record cut short by a crash at the end
+// of the log is discarded, any other malformed record fails the replay.
+func (l *Log) Replay(ctx context.Context, c cache.ILRUCache) (records int, err error) {
+	l.replaying.Store(true)
+	defer l.replaying.Store(false)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(l.file)
+	valid := int64(0)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// The last write was interrupted, drop it so that the next one starts on a new line.
+				l.size, l.base = valid, valid
+				return records, l.file.Truncate(valid)
+			}
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return records, fmt.Errorf("aof: record at offset %d: %w", valid, err)
+		}
+		if err := apply(ctx, c, rec); err != nil {
+			return records, fmt.Errorf("aof: record at offset %d: %w", valid, err)
+		}
+		valid += int64(len(line))
+		records++
+	}
+}
+
+func apply(ctx context.Context, c cache.ILRUCache, rec record) error {
+	switch rec.Op {
+	case opSet:
+		ttl := time.Duration(0)
+		if rec.ExpiresAt != 0 {
+			if ttl = time.Until(time.Unix(0, rec.ExpiresAt)); ttl <= 0 {
+				c.Evict(ctx, rec.Key)
+				return nil
+			}
+		}
+		return c.Put(ctx, rec.Key, rec.Value, ttl)
+	case opGet:
+		// A read only moves the entry, it must not load a missing key from a backing store.
+		if _, _, err := cache.Peek(ctx, c, rec.Key); err == nil {
+			c.Get(ctx, rec.Key)
+		}
+		return nil
+	case opDel:
+		c.Evict(ctx, rec.Key)
+		return nil
+	case opFlush:
+		return c.EvictAll(ctx)
+	}
+	return fmt.Errorf("unknown operation %q", rec.Op)
+}
+
+// Start starts flushing and syncing the log in the background according to the fsync policy,
+// and rewriting it from the entries returned by snapshot once it grows past the rewrite size.
+func (l *Log) Start(snapshot func() []lru.Entry[string, any]) {
+	l.snapshot = snapshot
+	go l.loop()
+}
+
+// Close flushes and syncs the log, then closes it.
+func (l *Log) Close() error {
+	if l.snapshot != nil {
+		close(l.stop)
+		<-l.done
+	}
+	err := l.flush()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return errors.Join(err, l.file.Close())
+}
+
+// Put records that value was stored under key until expiresAt.
+func (l *Log) Put(key string, value any, expiresAt time.Time) {
+	rec := record{Op: opSet, Key: key, Value: value}
+	if !expiresAt.IsZero() {
+		rec.ExpiresAt = expiresAt.UnixNano()
+	}
+	l.append(rec)
+}
+
+// Evict records that key was removed.
+func (l *Log) Evict(key string) {
+	l.append(record{Op: opDel, Key: key})
+}
+
+// EvictAll records that every key was removed.
+func (l *Log) EvictAll() {
+	l.append(record{Op: opFlush})
+}
+
+// Access records that key was read. Reads are only written with the next flush.
+func (l *Log) Access(key string) {
+	if l.replaying.Load() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	l.reads[key] = l.seq
+}
+
+func (l *Log) append(rec record) {
+	if l.replaying.Load() {
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		l.cfg.OnError(fmt.Errorf("aof: encode %q: %w", rec.Key, err))
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.write(line)
+}
+
+// Sync writes and syncs the buffered records with FsyncAlways, and does nothing with the other policies,
+// which flush once a second. The cache calls it once its lock is released, before the write returns.
+func (l *Log) Sync() {
+	if l.cfg.Fsync != FsyncAlways || l.replaying.Load() {
+		return
+	}
+	if err := l.flush(); err != nil {
+		l.cfg.OnError(fmt.Errorf("aof: sync: %w", err))
+	}
+}
+
+// write buffers a record, keeping it for the rewritten log too while a rewrite runs. It must be called with mu held.
+func (l *Log) write(line []byte) {
+	if l.rewriting {
+		l.pending = append(l.pending, line...)
+	}
+	l.buf = append(l.buf, line...)
+	l.size += int64(len(line))
+}
+
+// writeReads buffers a record for every key read since the last flush, in the order of their last read.
+// It must be called with mu held.
+func (l *Log) writeReads() {
+	keys := make([]string, 0, len(l.reads))
+	for key := range l.reads {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Compare(l.reads[a], l.reads[b])
+	})
+	for _, key := range keys {
+		line, _ := json.Marshal(record{Op: opGet, Key: key})
+		l.write(append(line, '\n'))
+	}
+	clear(l.reads)
+}
+
+// flush writes the buffered records to the file and, unless the policy is never, syncs it.
+// mu is only held to swap the buffers, so that writers, which hold the lock of the cache while they append
+// records, never wait for the disk.
+func (l *Log) flush() error {
+	l.flushMu.Lock()
+	defer l.flushMu.Unlock()
+
+	l.mu.Lock()
+	l.writeReads()
+	buf, file := l.buf, l.file
+	l.buf, l.spare = l.spare[:0], nil
+	l.mu.Unlock()
+
+	var err error
+	if len(buf) > 0 {
+		if _, err = file.Write(buf); err == nil && l.cfg.Fsync != FsyncNever {
+			err = file.Sync()
+		}
+	}
+
+	l.mu.Lock()
+	l.spare = buf[:0]
+	l.mu.Unlock()
+	return err
+}
+
+func (l *Log) loop() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		err := l.flush()
+		l.mu.Lock()
+		due := l.size >= l.cfg.RewriteSize && l.size >= 2*l.base
+		l.mu.Unlock()
+		if err != nil {
+			l.cfg.OnError(fmt.Errorf("aof: sync: %w", err))
+		}
+		if due {
+			if err := l.rewrite(); err != nil {
+				l.cfg.OnError(fmt.Errorf("aof: rewrite: %w", err))
+			}
+		}
+	}
+}
+
+// rewrite replaces the log with one record per entry of the cache, followed by the records
+// written while the entries were collected. Those may already be part of the entries,
+// which is harmless as replaying a record twice in a row leaves the cache in the same state.
+func (l *Log) rewrite() error {
+	l.mu.Lock()
+	l.rewriting, l.pending = true, nil
+	// The recency order of the entries already holds the reads made so far.
+	clear(l.reads)
+	l.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.cfg.Path), filepath.Base(l.cfg.Path)+".rewrite-*")
+	if err == nil {
+		err = writeEntries(tmp, l.snapshot())
+	}
+
+	l.flushMu.Lock()
+	defer l.flushMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pending := l.pending
+	l.rewriting, l.pending = false, nil
+	if err == nil {
+		err = l.replace(tmp, pending)
+	}
+	if err != nil && tmp != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// writeEntries writes a set record for every entry to w.
+func writeEntries(w io.Writer, entries []lru.Entry[string, any]) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		rec := record{Op: opSet, Key: e.Key, Value: e.Value}
+		if !e.ExpiresAt.IsZero() {
+			rec.ExpiresAt = e.ExpiresAt.UnixNano()
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		bw.Write(line)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// replace appends the pending records to the rewritten log in tmp and puts it in place of the current one.
+// The buffered records are dropped, as they are either part of the rewritten log or pending.
+func (l *Log) replace(tmp *os.File, pending []byte) error {
+	if _, err := tmp.Write(pending); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.cfg.Path); err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = tmp
+	l.buf = l.buf[:0]
+	l.size, l.base = info.Size(), info.Size()
+	return nil
+}
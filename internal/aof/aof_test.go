@@ -0,0 +1,144 @@
+package aof
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lru-cache/internal/cache"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// open opens the log at path and replays it into a new cache journaling to it.
+func open(t *testing.T, path string) (*Log, cache.ILRUCache) {
+	log, err := Open(Config{Path: path, Fsync: FsyncAlways})
+	assert.NoError(t, err)
+	c := cache.New(10, lru.WithJournal[string, any](log))
+	_, err = log.Replay(context.Background(), c)
+	assert.NoError(t, err)
+	return log, c
+}
+
+// TestReplay checks that replaying the log rebuilds the contents and recency order of the cache,
+// dropping a record cut short at the end of the log.
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	log, c := open(t, path)
+
+	c.Put(ctx, "gone", 0.0, 0)
+	c.EvictAll(ctx)
+	c.Put(ctx, "a", "1", time.Hour)
+	c.Put(ctx, "b", 2.0, 0)
+	c.Put(ctx, "c", true, time.Hour)
+	c.Put(ctx, "expired", "x", time.Millisecond)
+	c.Put(ctx, "a", "3", time.Hour)
+	c.Evict(ctx, "c")
+	assert.NoError(t, log.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	f.WriteString(`{"op":"set","key":"torn"`)
+	f.Close()
+	time.Sleep(5 * time.Millisecond)
+
+	log, c = open(t, path)
+	c.Put(ctx, "d", "4", 0)
+	assert.NoError(t, log.Close())
+
+	log, c = open(t, path)
+	defer log.Close()
+	keys, values, err := c.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a", "d"}, keys)
+	assert.Equal(t, []any{2.0, "3", "4"}, values)
+
+	_, err = ParseFsync("sometimes")
+	assert.ErrorIs(t, err, errs.ErrUnknownFsync)
+}
+
+// TestReplayReads checks that replaying the log restores the recency order left by reads,
+// without bringing back keys removed after they were read.
+func TestReplayReads(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	log, c := open(t, path)
+
+	c.Put(ctx, "a", "1", 0)
+	c.Put(ctx, "b", "2", 0)
+	c.Put(ctx, "c", "3", 0)
+	c.Put(ctx, "d", "4", 0)
+	c.Get(ctx, "b")
+	c.Get(ctx, "a")
+	c.Get(ctx, "d")
+	c.Evict(ctx, "d")
+	assert.NoError(t, log.Close())
+
+	log, c = open(t, path)
+	defer log.Close()
+	keys, _, err := c.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "b", "a"}, keys)
+}
+
+// TestFsyncAlways checks that with FsyncAlways a write is in the file once it returns, while reads wait for the next write.
+func TestFsyncAlways(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	log, c := open(t, path)
+	defer log.Close()
+
+	c.Put(ctx, "a", "1", 0)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"op":"set","key":"a","value":"1"}`+"\n", string(data))
+
+	c.Get(ctx, "a")
+	c.Evict(ctx, "a")
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"op":"set","key":"a","value":"1"}`+"\n"+`{"op":"del","key":"a"}`+"\n"+`{"op":"get","key":"a"}`+"\n", string(data))
+}
+
+// TestRewrite checks that a rewrite compacts the log to one record per live entry in recency order,
+// keeping the writes made while it runs.
+func TestRewrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	log, err := Open(Config{Path: path, Fsync: FsyncAlways})
+	assert.NoError(t, err)
+	c := cache.New(3, lru.WithJournal[string, any](log))
+	for i := range 100 {
+		c.Put(ctx, fmt.Sprintf("%d", i%5), float64(i), 0)
+	}
+	c.Get(ctx, "2")
+
+	log.snapshot = func() []lru.Entry[string, any] {
+		entries := c.(cache.Snapshotter).Snapshot()
+		c.Put(ctx, "during", "rewrite", 0)
+		return entries
+	}
+	before := log.size
+	assert.NoError(t, log.rewrite())
+	assert.Less(t, log.size, before/10)
+	c.Put(ctx, "after", "rewrite", 0)
+	log.snapshot = nil
+	assert.NoError(t, log.Close())
+
+	log, err = Open(Config{Path: path})
+	assert.NoError(t, err)
+	defer log.Close()
+	replayed := cache.New(10)
+	records, err := log.Replay(ctx, replayed)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, records)
+	keys, _, err := replayed.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3", "4", "2", "during", "after"}, keys)
+}
@@ -4,8 +4,13 @@ package cache
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"hash/maphash"
 	"lru-cache/pkg/errs"
-	"sync"
+	"lru-cache/pkg/lru"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,102 +28,428 @@ type ILRUCache interface {
 	EvictAll(ctx context.Context) error
 }
 
-type node struct {
-	key       string
-	value     interface{}
-	expiresAt time.Time
-	prev      *node
-	next      *node
+// Stater is implemented by caches that report usage statistics.
+type Stater interface {
+	// Stats returns a snapshot of the cache counters.
+	Stats() lru.Stats
 }
 
+// EvictNotifier is implemented by caches that report the entries they remove.
+type EvictNotifier interface {
+	// OnEvict registers fn to be called, outside of the cache lock, for every entry that leaves the cache.
+	OnEvict(fn func(key string, value any, reason lru.EvictReason))
+}
+
+// Loader is implemented by caches that can load missing values themselves.
+type Loader interface {
+	// GetOrLoad retrieves data from the cache by key, calling load on a miss and storing what it returns.
+	// Concurrent misses for the same key share a single call of load.
+	GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (value any, ttl time.Duration, err error)) (value any, err error)
+}
+
+// ItemGetter is implemented by caches that tell whether an entry is stale or being refreshed.
+type ItemGetter interface {
+	// GetItem retrieves an entry from the cache by key along with the state of its TTL.
+	GetItem(ctx context.Context, key string) (item lru.Item[any], err error)
+}
+
+// Snapshotter is implemented by caches that can dump their contents and load them back.
+type Snapshotter interface {
+	// Snapshot returns the live entries of the cache ordered from the least to the most recently used one.
+	Snapshot() []lru.Entry[string, any]
+	// Restore stores the entries in order, keeping their expiration times and skipping expired ones.
+	Restore(entries []lru.Entry[string, any])
+}
+
+// ConditionalPutter is implemented by caches that can store data depending on whether the key exists.
+type ConditionalPutter interface {
+	// PutIf stores data in the cache like Put if the condition of mode holds, checked atomically.
+	// Returns whether the data was stored.
+	PutIf(ctx context.Context, key string, value any, ttl time.Duration, mode lru.Mode) (stored bool, err error)
+}
+
+// Expirer is implemented by caches that can change the TTL of an entry.
+type Expirer interface {
+	// Expire sets the TTL of the entry stored under key, a non-positive TTL meaning it never expires.
+	// Returns an error if the key is not found or has expired.
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// Peeker is implemented by caches that can read an entry without counting the read or updating its recency.
+type Peeker interface {
+	// Peek retrieves data from the cache by key like Get, leaving the recency of the entry unchanged.
+	Peek(ctx context.Context, key string) (value any, expiresAt time.Time, err error)
+}
+
+// Versioner is implemented by caches that version their entries, see lru.Item.Version.
+type Versioner interface {
+	// PutIfVersion stores data in the cache like Put if the live entry stored under key has the given version,
+	// checked atomically. Returns the version of the live entry after the call, 0 if there is none,
+	// and whether the data was stored.
+	PutIfVersion(ctx context.Context, key string, value any, ttl time.Duration, version uint64) (current uint64, stored bool, err error)
+	// Version returns the version of the live entry stored under key, without counting the read or updating its recency.
+	// Returns an error if the key is not found or has expired.
+	Version(ctx context.Context, key string) (version uint64, err error)
+}
+
+// Incrementer is implemented by caches that can increment numeric values atomically.
+type Incrementer interface {
+	// Incr adds delta to the integer stored under key, storing delta with ttl if the key is not found or has expired.
+	// An existing entry keeps its expiration time. Returns the new value and its expiration time,
+	// errs.ErrNotNumeric or errs.ErrNotInteger if the value is not an integer and errs.ErrOverflow if the sum overflows.
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (value int64, expiresAt time.Time, err error)
+	// IncrFloat adds delta to the number stored under key like Incr, as a float.
+	IncrFloat(ctx context.Context, key string, delta float64, ttl time.Duration) (value float64, expiresAt time.Time, err error)
+}
+
+// Batcher is implemented by caches that can read, store and remove several keys taking their locks once.
+type Batcher interface {
+	// GetMany retrieves data from the cache for every key. The results are at the positions of their keys,
+	// with errs.ErrNotFound for the keys not found or expired.
+	GetMany(ctx context.Context, keys []string) (results []Result, err error)
+	// PutMany stores values[i] under keys[i] with ttls[i] like Put, in order.
+	// stored[i] is false if values[i] was not admitted into the cache, see lru.Cache.PutMany.
+	PutMany(ctx context.Context, keys []string, values []any, ttls []time.Duration) (stored []bool, err error)
+	// EvictMany removes data for every key. The results hold the removed values at the positions of their keys,
+	// with errs.ErrNotFound for the keys not found or expired.
+	EvictMany(ctx context.Context, keys []string) (results []Result, err error)
+}
+
+// Scanner is implemented by caches that can list their entries page by page.
+type Scanner interface {
+	// Scan returns a page of at most limit live entries whose key satisfies match, nil matching every key,
+	// walking the cache in the given order from cursor, empty to start a scan. It also returns the cursor
+	// of the next page, empty once the scan is complete, or errs.ErrInvalidCursor if cursor is malformed.
+	// A page may hold fewer entries than limit before the scan is complete, see lru.Cache.Scan.
+	Scan(ctx context.Context, cursor string, limit int, order lru.Order, match func(key string) bool) (entries []lru.Entry[string, any], next string, err error)
+}
+
+// Result is the outcome of a batch operation for a single key.
+type Result struct {
+	Item lru.Item[any]
+	Err  error
+}
+
+// PutIf stores data in c if the condition of mode holds, atomically if c is a ConditionalPutter.
+// Returns whether the data was stored.
+func PutIf(ctx context.Context, c ILRUCache, key string, value any, ttl time.Duration, mode lru.Mode) (stored bool, err error) {
+	if putter, ok := c.(ConditionalPutter); ok {
+		return putter.PutIf(ctx, key, value, ttl, mode)
+	}
+	_, _, err = Peek(ctx, c, key)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return false, err
+	}
+	if (err == nil) != (mode == lru.IfPresent) {
+		return false, nil
+	}
+	return true, c.Put(ctx, key, value, ttl)
+}
+
+// Peek retrieves data from c by key, without updating its recency if c is a Peeker.
+func Peek(ctx context.Context, c ILRUCache, key string) (value any, expiresAt time.Time, err error) {
+	if peeker, ok := c.(Peeker); ok {
+		return peeker.Peek(ctx, key)
+	}
+	return c.Get(ctx, key)
+}
+
+// GetItem retrieves an entry from c by key, with the state of its TTL and its version if c is an ItemGetter.
+func GetItem(ctx context.Context, c ILRUCache, key string) (item lru.Item[any], err error) {
+	if getter, ok := c.(ItemGetter); ok {
+		return getter.GetItem(ctx, key)
+	}
+	item.Value, item.ExpiresAt, err = c.Get(ctx, key)
+	return item, err
+}
+
+// Incr adds delta to the integer stored under key in c, atomically if c is an Incrementer. See Incrementer.Incr.
+func Incr(ctx context.Context, c ILRUCache, key string, delta int64, ttl time.Duration) (value int64, expiresAt time.Time, err error) {
+	if incrementer, ok := c.(Incrementer); ok {
+		return incrementer.Incr(ctx, key, delta, ttl)
+	}
+	return update(ctx, c, key, ttl, func(current any, ok bool) (int64, error) {
+		return addInt(current, ok, delta)
+	})
+}
+
+// IncrFloat adds delta to the number stored under key in c, atomically if c is an Incrementer. See Incrementer.IncrFloat.
+func IncrFloat(ctx context.Context, c ILRUCache, key string, delta float64, ttl time.Duration) (value float64, expiresAt time.Time, err error) {
+	if incrementer, ok := c.(Incrementer); ok {
+		return incrementer.IncrFloat(ctx, key, delta, ttl)
+	}
+	return update(ctx, c, key, ttl, func(current any, ok bool) (float64, error) {
+		return addFloat(current, ok, delta)
+	})
+}
+
+// update replaces the value stored under key in c with the one computed by fn, non atomically,
+// storing a new entry with ttl and keeping the expiration time of an existing one.
+func update[T any](ctx context.Context, c ILRUCache, key string, ttl time.Duration, fn func(current any, ok bool) (T, error)) (value T, expiresAt time.Time, err error) {
+	current, expiresAt, err := Peek(ctx, c, key)
+	found := err == nil
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return value, time.Time{}, err
+	}
+	if value, err = fn(current, found); err != nil {
+		return value, time.Time{}, err
+	}
+	switch {
+	case found && expiresAt.IsZero():
+		ttl = 0
+	case found:
+		ttl = time.Until(expiresAt)
+		if ttl <= 0 {
+			// The entry expired in the meantime, so the new value is gone too.
+			return value, expiresAt, nil
+		}
+	case ttl > 0:
+		expiresAt = time.Now().Add(ttl)
+	}
+	return value, expiresAt, c.Put(ctx, key, value, ttl)
+}
+
+// GetMany retrieves data from c for every key, in a single batch if c is a Batcher.
+// Errors other than errs.ErrNotFound are returned for the whole batch.
+func GetMany(ctx context.Context, c ILRUCache, keys []string) (results []Result, err error) {
+	if batcher, ok := c.(Batcher); ok {
+		return batcher.GetMany(ctx, keys)
+	}
+	results = make([]Result, len(keys))
+	for i, key := range keys {
+		results[i].Item, err = GetItem(ctx, c, key)
+		if err != nil && !errors.Is(err, errs.ErrNotFound) {
+			return nil, err
+		}
+		results[i].Err = err
+	}
+	return results, nil
+}
+
+// PutMany stores values[i] under keys[i] with ttls[i] in c, in a single batch if c is a Batcher,
+// reporting whether every value was stored. Otherwise it stops at the first failure, keeping the values
+// already stored, and reports every value put without error as stored.
+func PutMany(ctx context.Context, c ILRUCache, keys []string, values []any, ttls []time.Duration) (stored []bool, err error) {
+	if batcher, ok := c.(Batcher); ok {
+		return batcher.PutMany(ctx, keys, values, ttls)
+	}
+	stored = make([]bool, len(keys))
+	for i, key := range keys {
+		if err := c.Put(ctx, key, values[i], ttls[i]); err != nil {
+			return nil, err
+		}
+		stored[i] = true
+	}
+	return stored, nil
+}
+
+// EvictMany removes data from c for every key, in a single batch if c is a Batcher.
+// Errors other than errs.ErrNotFound are returned for the whole batch.
+func EvictMany(ctx context.Context, c ILRUCache, keys []string) (results []Result, err error) {
+	if batcher, ok := c.(Batcher); ok {
+		return batcher.EvictMany(ctx, keys)
+	}
+	results = make([]Result, len(keys))
+	for i, key := range keys {
+		results[i].Item.Value, err = c.Evict(ctx, key)
+		if errors.Is(err, errs.ErrCacheIsEmpty) {
+			err = errs.ErrNotFound
+		}
+		if err != nil && !errors.Is(err, errs.ErrNotFound) {
+			return nil, err
+		}
+		results[i].Err = err
+	}
+	return results, nil
+}
+
+// engine is the part of the generic cache API the adapter relies on.
+// It is implemented by both lru.Cache and lru.Sharded.
+type engine interface {
+	Put(key string, value any, ttl time.Duration)
+	Get(key string) (value any, expiresAt time.Time, ok bool)
+	GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (any, time.Duration, error)) (any, error)
+	Lookup(key string) (item lru.Item[any], ok bool)
+	Peek(key string) (value any, expiresAt time.Time, ok bool)
+	Snapshot() []lru.Entry[string, any]
+	Restore(entries []lru.Entry[string, any])
+	PutIf(key string, value any, ttl time.Duration, mode lru.Mode) bool
+	PutIfVersion(key string, value any, ttl time.Duration, version uint64) (current uint64, stored bool)
+	PeekItem(key string) (item lru.Item[any], ok bool)
+	Update(key string, ttl time.Duration, fn func(value any, ok bool) (any, error)) (item lru.Item[any], err error)
+	Expire(key string, ttl time.Duration) bool
+	All() (keys []string, values []any)
+	Evict(key string) (value any, ok bool)
+	EvictAll()
+	GetMany(keys []string) (items []lru.Item[any], ok []bool)
+	PutMany(keys []string, values []any, ttls []time.Duration) (stored []bool)
+	EvictMany(keys []string) (values []any, ok []bool)
+	Scan(cursor lru.Cursor[string], limit int, order lru.Order, match func(string) bool) (entries []lru.Entry[string, any], next lru.Cursor[string])
+	Len() int
+	Stats() lru.Stats
+	OnEvict(fn func(key string, value any, reason lru.EvictReason))
+	Close() error
+}
+
+// cache adapts the generic lru caches to the ILRUCache interface.
 type cache struct {
-	capacity int
-	data     map[string]*node
-	left     *node
-	right    *node
-  mu sync.Mutex
+	lru engine
+}
+
+// New creates a new LRU cache with the specified capacity and options.
+// Returns an instance of the ILRUCache interface, which also implements io.Closer
+// to stop the background work enabled by the options.
+func New(capacity int, opts ...lru.Option[string, any]) ILRUCache {
+	return &cache{lru: lru.New(capacity, opts...)}
 }
 
-// New creates a new LRU cache with the specified capacity.
+// NewSharded creates a new LRU cache with the specified total capacity split into independent shards,
+// each guarded by its own lock, to scale under parallel load. The options are applied to every shard.
 // Returns an instance of the ILRUCache interface.
-func New(capacity int) ILRUCache {
-	ret := cache{
-		capacity: capacity,
-		data:     make(map[string]*node, capacity),
-		left:     &node{key: "", value: ""},
-		right:    &node{key: "", value: ""},
+func NewSharded(capacity, shards int, opts ...lru.Option[string, any]) ILRUCache {
+	return &cache{lru: lru.NewSharded(capacity, shards, newHash(), opts...)}
+}
+
+// WithAdmission returns an option enabling the TinyLFU admission filter. See lru.WithAdmission.
+func WithAdmission() lru.Option[string, any] {
+	return lru.WithAdmission[string, any](newHash())
+}
+
+// newHash returns a randomly seeded hash function for keys.
+func newHash() func(key string) uint64 {
+	seed := maphash.MakeSeed()
+	return func(key string) uint64 {
+		return maphash.String(seed, key)
 	}
-	ret.left.next, ret.right.prev = ret.right, ret.left
-	return &ret
+}
+
+// OnEvict registers fn to be called for every entry that leaves the cache, with the reason it left.
+func (c *cache) OnEvict(fn func(key string, value any, reason lru.EvictReason)) {
+	c.lru.OnEvict(fn)
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *cache) Stats() lru.Stats {
+	return c.lru.Stats()
+}
+
+// Snapshot returns the live entries of the cache ordered from the least to the most recently used one.
+func (c *cache) Snapshot() []lru.Entry[string, any] {
+	return c.lru.Snapshot()
+}
+
+// Restore stores the entries in order, keeping their expiration times and skipping expired ones.
+func (c *cache) Restore(entries []lru.Entry[string, any]) {
+	c.lru.Restore(entries)
+}
+
+// Close stops the background goroutines of the cache.
+func (c *cache) Close() error {
+	return c.lru.Close()
 }
 
 // Put stores data in the cache with a specified TTL.
 // If the key already exists, the existing entry is updated.
 func (c *cache) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-  c.mu.Lock()
-  defer c.mu.Unlock()
+	c.lru.Put(key, value, ttl)
+	return nil
+}
+
+// Get retrieves data from the cache by key.
+// Returns the value, expiration time, and an error if the key is not found or has expired.
+func (c *cache) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
+	value, expiresAt, ok := c.lru.Get(key)
+	if !ok {
+		return nil, time.Time{}, errs.ErrNotFound
+	}
+	return value, expiresAt, nil
+}
 
-	if _, ok := c.data[key]; ok {
-		c.remove(c.data[key])
+// PutIf stores data in the cache if the condition of mode holds. Returns whether the data was stored.
+func (c *cache) PutIf(ctx context.Context, key string, value any, ttl time.Duration, mode lru.Mode) (stored bool, err error) {
+	return c.lru.PutIf(key, value, ttl, mode), nil
+}
+
+// PutIfVersion stores data in the cache if the live entry stored under key has the given version.
+// Returns the version of the live entry after the call, 0 if there is none, and whether the data was stored.
+func (c *cache) PutIfVersion(ctx context.Context, key string, value any, ttl time.Duration, version uint64) (current uint64, stored bool, err error) {
+	current, stored = c.lru.PutIfVersion(key, value, ttl, version)
+	return current, stored, nil
+}
+
+// Version returns the version of the live entry stored under key. Returns an error if the key is not found or has expired.
+func (c *cache) Version(ctx context.Context, key string) (version uint64, err error) {
+	item, ok := c.lru.PeekItem(key)
+	if !ok {
+		return 0, errs.ErrNotFound
 	}
-	c.data[key] = &node{key: key, value: value, expiresAt: time.Now().Add(ttl)}
-	c.insert(c.data[key])
+	return item.Version, nil
+}
 
-	if len(c.data) > c.capacity {
-		nd := c.left.next
-		c.remove(nd)
-		delete(c.data, nd.key)
+// Incr atomically adds delta to the integer stored under key, storing delta with ttl if the key is not found or has expired.
+// An existing entry keeps its expiration time. Returns the new value and its expiration time.
+func (c *cache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (value int64, expiresAt time.Time, err error) {
+	item, err := c.lru.Update(key, ttl, func(current any, ok bool) (any, error) {
+		return addInt(current, ok, delta)
+	})
+	if err != nil {
+		return 0, time.Time{}, err
 	}
-	return nil
+	return item.Value.(int64), item.ExpiresAt, nil
 }
 
-func (c *cache) insert(nd *node) {
-	prev, nxt := c.right.prev, c.right
-	nxt.prev = nd
-	prev.next = nxt.prev
-	nd.next, nd.prev = nxt, prev
+// IncrFloat atomically adds delta to the number stored under key like Incr, as a float.
+func (c *cache) IncrFloat(ctx context.Context, key string, delta float64, ttl time.Duration) (value float64, expiresAt time.Time, err error) {
+	item, err := c.lru.Update(key, ttl, func(current any, ok bool) (any, error) {
+		return addFloat(current, ok, delta)
+	})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return item.Value.(float64), item.ExpiresAt, nil
 }
 
-func (c *cache) remove(nd *node) {
-	prev, nxt := nd.prev, nd.next
-	prev.next, nxt.prev = nxt, prev
+// Expire sets the TTL of the entry stored under key. Returns an error if the key is not found or has expired.
+func (c *cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if !c.lru.Expire(key, ttl) {
+		return errs.ErrNotFound
+	}
+	return nil
 }
 
-// Get retrieves data from the cache by key.
-// Returns the value, expiration time, and an error if the key is not found or has expired.
-func (c *cache) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
-  c.mu.Lock()
-  defer c.mu.Unlock()
-
-	if nd, ok := c.data[key]; ok {
-		if nd.expiresAt.Before(time.Now()) {
-			c.remove(nd)
-			delete(c.data, key)
-			return nil, time.Time{}, errs.ErrNotFound
-		}
-		c.remove(nd)
-		c.insert(nd)
-		return nd.value, nd.expiresAt, nil
+// Peek retrieves data from the cache by key without counting the read or updating the recency of the entry.
+func (c *cache) Peek(ctx context.Context, key string) (value any, expiresAt time.Time, err error) {
+	value, expiresAt, ok := c.lru.Peek(key)
+	if !ok {
+		return nil, time.Time{}, errs.ErrNotFound
 	}
-	return nil, time.Time{}, errs.ErrNotFound
+	return value, expiresAt, nil
+}
+
+// GetItem retrieves an entry from the cache by key, telling whether it is served stale
+// or being refreshed (see lru.WithRefreshAhead and lru.WithStaleGrace).
+// Returns an error if the key is not found or has expired past its grace period.
+func (c *cache) GetItem(ctx context.Context, key string) (item lru.Item[any], err error) {
+	item, ok := c.lru.Lookup(key)
+	if !ok {
+		return item, errs.ErrNotFound
+	}
+	return item, nil
+}
+
+// GetOrLoad retrieves data from the cache by key. On a miss it calls load, stores the value it returns
+// with the returned TTL and returns it. Concurrent misses for the same key share a single call of load.
+// Errors returned by load are passed through and are not cached unless lru.WithNegativeCaching is used.
+func (c *cache) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (value any, ttl time.Duration, err error)) (value any, err error) {
+	return c.lru.GetOrLoad(ctx, key, load)
 }
 
 // GetAll retrieves all entries from the cache as two slices: a slice of keys and a slice of values.
 // Returns an error if the cache is empty.
 func (c *cache) GetAll(ctx context.Context) (keys []string, values []interface{}, err error) {
-  c.mu.Lock()
-  defer c.mu.Unlock()
-
-	if len(c.data) == 0 {
-		err = errs.ErrCacheIsEmpty
-		return
-	}
-	keys = make([]string, len(c.data))
-	values = make([]interface{}, len(c.data))
-	i := 0
-	for k, v := range c.data {
-		keys[i], values[i] = k, v.value
-		i++
+	keys, values = c.lru.All()
+	if len(keys) == 0 {
+		return nil, nil, errs.ErrCacheIsEmpty
 	}
 	return keys, values, nil
 }
@@ -126,34 +457,96 @@ func (c *cache) GetAll(ctx context.Context) (keys []string, values []interface{}
 // Evict manually removes data by key from the cache.
 // Returns the value and an error if the key is not found or has expired.
 func (c *cache) Evict(ctx context.Context, key string) (value interface{}, err error) {
-  c.mu.Lock()
-  defer c.mu.Unlock()
-
-	if len(c.data) == 0 {
-		err = errs.ErrCacheIsEmpty
-		return
-	}
-	if nd, ok := c.data[key]; ok {
-		if nd.expiresAt.Before(time.Now()) {
-			c.remove(nd)
-			delete(c.data, key)
-			return nil, errs.ErrNotFound
-		}
-		c.remove(nd)
-		delete(c.data, nd.key)
-		return nd.value, nil
+	if c.lru.Len() == 0 {
+		return nil, errs.ErrCacheIsEmpty
 	}
-
-	return nil, errs.ErrNotFound
+	value, ok := c.lru.Evict(key)
+	if !ok {
+		return nil, errs.ErrNotFound
+	}
+	return value, nil
 }
 
 // EvictAll manually invalidates the entire cache.
 func (c *cache) EvictAll(ctx context.Context) error {
-  c.mu.Lock()
-  defer c.mu.Unlock()
+	c.lru.EvictAll()
+	return nil
+}
 
-	c.left.next, c.right.prev = c.right, c.left
-	clear(c.data)
+// GetMany retrieves data from the cache for every key, taking the lock of every shard involved once.
+// The results are at the positions of their keys, with errs.ErrNotFound for the keys not found or expired.
+func (c *cache) GetMany(ctx context.Context, keys []string) (results []Result, err error) {
+	items, ok := c.lru.GetMany(keys)
+	results = make([]Result, len(keys))
+	for i := range results {
+		results[i].Item = items[i]
+		if !ok[i] {
+			results[i].Err = errs.ErrNotFound
+		}
+	}
+	return results, nil
+}
 
-	return nil
-}
\ No newline at end of file
+// PutMany stores values[i] under keys[i] with ttls[i], taking the lock of every shard involved once.
+// stored[i] is false if values[i] was not admitted into the cache.
+func (c *cache) PutMany(ctx context.Context, keys []string, values []any, ttls []time.Duration) (stored []bool, err error) {
+	return c.lru.PutMany(keys, values, ttls), nil
+}
+
+// EvictMany removes data for every key, taking the lock of every shard involved once.
+// The results hold the removed values at the positions of their keys, with errs.ErrNotFound for the keys not found or expired.
+func (c *cache) EvictMany(ctx context.Context, keys []string) (results []Result, err error) {
+	values, ok := c.lru.EvictMany(keys)
+	results = make([]Result, len(keys))
+	for i := range results {
+		results[i].Item.Value = values[i]
+		if !ok[i] {
+			results[i].Err = errs.ErrNotFound
+		}
+	}
+	return results, nil
+}
+
+// Scan returns a page of at most limit live entries whose key satisfies match, walking the cache in the given order
+// from cursor, with the cursor of the next page. Returns errs.ErrInvalidCursor if cursor is malformed.
+func (c *cache) Scan(ctx context.Context, cursor string, limit int, order lru.Order, match func(key string) bool) (entries []lru.Entry[string, any], next string, err error) {
+	from, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	entries, to := c.lru.Scan(from, limit, order, match)
+	return entries, encodeCursor(to), nil
+}
+
+// encodeCursor encodes a scan position as an opaque string, empty for the zero cursor.
+func encodeCursor(cursor lru.Cursor[string]) string {
+	if cursor == (lru.Cursor[string]{}) {
+		return ""
+	}
+	raw := strconv.Itoa(cursor.Shard) + ":" + strconv.FormatUint(cursor.Seq, 10) + ":" + cursor.Key
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor decodes a cursor encoded by encodeCursor.
+func decodeCursor(cursor string) (lru.Cursor[string], error) {
+	if cursor == "" {
+		return lru.Cursor[string]{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return lru.Cursor[string]{}, errs.ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 {
+		return lru.Cursor[string]{}, errs.ErrInvalidCursor
+	}
+	shard, err := strconv.Atoi(parts[0])
+	if err != nil || shard < 0 {
+		return lru.Cursor[string]{}, errs.ErrInvalidCursor
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return lru.Cursor[string]{}, errs.ErrInvalidCursor
+	}
+	return lru.Cursor[string]{Shard: shard, Seq: seq, Key: parts[2]}, nil
+}
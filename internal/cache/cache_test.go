@@ -3,7 +3,12 @@ package cache
 import (
 	"context"
 	"fmt"
+	"io"
 	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -132,3 +137,380 @@ func TestLeastRecntlyAddedKeyIsEvicted(t *testing.T) {
 	assert.Equal(t, time.Time{}, expiresAt)
 	assert.Equal(t, errs.ErrNotFound, err)
 }
+
+// TestSharded verifies that the sharded cache behaves like the regular one through the ILRUCache interface.
+func TestSharded(t *testing.T) {
+	cache := NewSharded(400, 8)
+
+	for i := range 50 {
+		err := cache.Put(context.Background(), fmt.Sprintf("%d", i), i, time.Hour)
+		assert.NoError(t, err)
+	}
+	for i := range 50 {
+		got, _, err := cache.Get(context.Background(), fmt.Sprintf("%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, got)
+	}
+
+	keys, values, err := cache.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, keys, 50)
+	assert.Len(t, values, 50)
+
+	got, err := cache.Evict(context.Background(), "7")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, got)
+
+	assert.NoError(t, cache.EvictAll(context.Background()))
+	_, _, err = cache.GetAll(context.Background())
+	assert.Equal(t, errs.ErrCacheIsEmpty, err)
+}
+
+func benchmarkParallel(b *testing.B, cache ILRUCache) {
+	keys := make([]string, 1<<12)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%d", i)
+		cache.Put(context.Background(), keys[i], i, time.Hour)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				cache.Put(context.Background(), key, i, time.Hour)
+			} else {
+				cache.Get(context.Background(), key)
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkParallel compares the single-lock cache with the sharded one under parallel mixed load.
+func BenchmarkParallel(b *testing.B) {
+	b.Run("single", func(b *testing.B) {
+		benchmarkParallel(b, New(1<<11))
+	})
+	for _, shards := range []int{4, 16, 64} {
+		b.Run(fmt.Sprintf("sharded-%d", shards), func(b *testing.B) {
+			benchmarkParallel(b, NewSharded(1<<11, shards))
+		})
+	}
+}
+
+// TestMaxBytes verifies that the cache bounded in bytes weighs JSON-decoded values and evicts by size.
+func TestMaxBytes(t *testing.T) {
+	assert.Equal(t, int64(16+1+16+16+5), Weigh("k", "value"))
+	assert.Greater(t, Weigh("k", map[string]any{"a": []any{"b", true}}), Weigh("k", "ab"))
+
+	cache := New(0, WithMaxBytes(3*Weigh("0", 0.0)))
+	for i := range 5 {
+		cache.Put(context.Background(), fmt.Sprintf("%d", i), float64(i), time.Hour)
+	}
+	keys, _, err := cache.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "3", "4"}, keys)
+	assert.Equal(t, 3*Weigh("0", 0.0), cache.(Stater).Stats().Weight)
+}
+
+// TestOnEvict verifies that eviction listeners registered through the adapter see removals from every shard.
+func TestOnEvict(t *testing.T) {
+	cache := NewSharded(20, 4)
+	var evicted []string
+	cache.(EvictNotifier).OnEvict(func(key string, value any, reason lru.EvictReason) {
+		assert.Equal(t, lru.ReasonExplicit, reason)
+		evicted = append(evicted, key)
+	})
+
+	for i := range 5 {
+		cache.Put(context.Background(), fmt.Sprintf("%d", i), i, time.Hour)
+	}
+	for i := range 5 {
+		cache.Evict(context.Background(), fmt.Sprintf("%d", i))
+	}
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, evicted)
+}
+
+// TestFileStoreLongKey verifies that keys too long to make a file name are stored under a hash of the key.
+func TestFileStoreLongKey(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	long := strings.Repeat("k", 1000)
+
+	assert.NoError(t, store.Save(ctx, long, "1", time.Time{}))
+	assert.NoError(t, store.Save(ctx, "short", "2", time.Time{}))
+	value, _, err := store.Load(ctx, long)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+	_, _, err = store.Load(ctx, long[:999])
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+
+	assert.NoError(t, store.Delete(ctx, long))
+	_, _, err = store.Load(ctx, long)
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+	value, _, err = store.Load(ctx, "short")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", value)
+}
+
+// TestBackedUnsupported verifies that only caches created by New or NewSharded can be backed by a store.
+func TestBackedUnsupported(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	_, err = Backed(NewMockCache(), store, StoreConfig{})
+	assert.ErrorIs(t, err, errs.ErrNotBackable)
+}
+
+// TestBackedWriteThrough verifies that writes reach the store synchronously and misses are loaded from it.
+func TestBackedWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	cache, err := Backed(New(2), store, StoreConfig{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.Put(ctx, "a", "1", time.Hour))
+	assert.NoError(t, cache.Put(ctx, "b", "2", 0))
+	assert.NoError(t, cache.Put(ctx, "c", "3", time.Hour))
+
+	value, expiresAt, err := store.Load(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+	// "a" was evicted from the cache by capacity but is still in the store.
+	value, expiresAt, err = cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+	_, err = cache.Evict(ctx, "a")
+	assert.NoError(t, err)
+	_, _, err = store.Load(ctx, "a")
+	assert.Equal(t, errs.ErrNotFound, err)
+	_, _, err = cache.Get(ctx, "a")
+	assert.Equal(t, errs.ErrNotFound, err)
+
+	assert.NoError(t, cache.EvictAll(ctx))
+	value, _, err = cache.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", value)
+	assert.NoError(t, cache.(io.Closer).Close())
+}
+
+// TestBackedBatches verifies that batch operations go through to the backing store.
+func TestBackedBatches(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	cache, err := Backed(New(2), store, StoreConfig{})
+	assert.NoError(t, err)
+
+	stored, err := PutMany(ctx, cache, []string{"a", "b", "c"}, []any{"1", "2", "3"}, []time.Duration{time.Hour, 0, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, true}, stored)
+	value, _, err := store.Load(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	// "a" was evicted from the cache by capacity but is loaded from the store.
+	results, err := GetMany(ctx, cache, []string{"a", "missing", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", results[0].Item.Value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), results[0].Item.ExpiresAt, time.Second)
+	assert.ErrorIs(t, results[1].Err, errs.ErrNotFound)
+	assert.Equal(t, "3", results[2].Item.Value)
+
+	_, err = EvictMany(ctx, cache, []string{"a", "b"})
+	assert.NoError(t, err)
+	_, _, err = store.Load(ctx, "b")
+	assert.Equal(t, errs.ErrNotFound, err)
+	results, err = GetMany(ctx, cache, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, errs.ErrNotFound)
+	assert.ErrorIs(t, results[1].Err, errs.ErrNotFound)
+	assert.NoError(t, cache.(io.Closer).Close())
+}
+
+// TestBackedWriteBehind verifies that queued writes are coalesced, visible to reads before they reach the store
+// and flushed on close.
+func TestBackedWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	files, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	store := &countingStore{Store: files}
+	cache, err := Backed(New(1), store, StoreConfig{WriteBehind: true, FlushInterval: time.Hour})
+	assert.NoError(t, err)
+
+	for i := range 10 {
+		assert.NoError(t, cache.Put(ctx, "a", i, time.Hour))
+	}
+	assert.NoError(t, cache.Put(ctx, "b", "b", time.Hour))
+	assert.NoError(t, cache.Put(ctx, "c", "c", time.Hour))
+	_, err = cache.Evict(ctx, "c")
+	assert.NoError(t, err)
+
+	// "a" was evicted from the cache and is only in the queue.
+	value, _, err := cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, value)
+	_, _, err = store.Load(ctx, "a")
+	assert.Equal(t, errs.ErrNotFound, err)
+
+	assert.NoError(t, cache.(io.Closer).Close())
+	value, _, err = store.Load(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 9.0, value)
+	_, _, err = store.Load(ctx, "c")
+	assert.Equal(t, errs.ErrNotFound, err)
+	assert.Equal(t, 3, store.writes)
+}
+
+// countingStore counts the writes reaching a store.
+type countingStore struct {
+	Store
+	writes int
+}
+
+func (s *countingStore) Save(ctx context.Context, key string, value any, expiresAt time.Time) error {
+	s.writes++
+	return s.Store.Save(ctx, key, value, expiresAt)
+}
+
+func (s *countingStore) Delete(ctx context.Context, key string) error {
+	s.writes++
+	return s.Store.Delete(ctx, key)
+}
+
+// TestBatches verifies that the batch helpers give the same per-key results
+// whether the cache implements Batcher or not.
+func TestBatches(t *testing.T) {
+	ctx := context.Background()
+	for name, c := range map[string]ILRUCache{"cache": New(10), "sharded": NewSharded(40, 4), "fallback": NewMockCache()} {
+		t.Run(name, func(t *testing.T) {
+			stored, err := PutMany(ctx, c, []string{"a", "b", "c"}, []any{1, "2", 3.0}, []time.Duration{time.Hour, 0, 0})
+			assert.NoError(t, err)
+			assert.Equal(t, []bool{true, true, true}, stored)
+
+			results, err := GetMany(ctx, c, []string{"c", "missing", "a"})
+			assert.NoError(t, err)
+			assert.Len(t, results, 3)
+			assert.Equal(t, 3.0, results[0].Item.Value)
+			assert.ErrorIs(t, results[1].Err, errs.ErrNotFound)
+			assert.Equal(t, 1, results[2].Item.Value)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), results[2].Item.ExpiresAt, time.Second)
+
+			results, err = EvictMany(ctx, c, []string{"b", "missing", "a", "c", "b"})
+			assert.NoError(t, err)
+			assert.Equal(t, "2", results[0].Item.Value)
+			assert.ErrorIs(t, results[1].Err, errs.ErrNotFound)
+			assert.NoError(t, results[2].Err)
+			assert.NoError(t, results[3].Err)
+			assert.ErrorIs(t, results[4].Err, errs.ErrNotFound)
+		})
+	}
+}
+
+// TestIncr verifies that increments create missing keys with the given TTL, keep the expiration time
+// of existing ones, reject values that are not numbers and are atomic.
+func TestIncr(t *testing.T) {
+	ctx := context.Background()
+	for name, c := range map[string]ILRUCache{"cache": New(10), "sharded": NewSharded(40, 4), "fallback": NewMockCache()} {
+		t.Run(name, func(t *testing.T) {
+			n, expiresAt, err := Incr(ctx, c, "n", 5, time.Hour)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(5), n)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+			n, again, err := Incr(ctx, c, "n", -7, time.Minute)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(-2), n)
+			assert.WithinDuration(t, expiresAt, again, time.Millisecond)
+			value, _, err := c.Get(ctx, "n")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(-2), value)
+
+			// Numbers decoded from JSON are floats.
+			assert.NoError(t, c.Put(ctx, "json", 2.0, 0))
+			n, expiresAt, err = Incr(ctx, c, "json", 1, time.Hour)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), n)
+			assert.True(t, expiresAt.IsZero())
+			f, _, err := IncrFloat(ctx, c, "json", 0.5, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, 3.5, f)
+			_, _, err = Incr(ctx, c, "json", 1, 0)
+			assert.ErrorIs(t, err, errs.ErrNotInteger)
+
+			assert.NoError(t, c.Put(ctx, "s", "1", 0))
+			_, _, err = Incr(ctx, c, "s", 1, 0)
+			assert.ErrorIs(t, err, errs.ErrNotNumeric)
+			_, _, err = IncrFloat(ctx, c, "s", 1, 0)
+			assert.ErrorIs(t, err, errs.ErrNotNumeric)
+			value, _, _ = c.Get(ctx, "s")
+			assert.Equal(t, "1", value)
+
+			assert.NoError(t, c.Put(ctx, "max", int64(math.MaxInt64), 0))
+			_, _, err = Incr(ctx, c, "max", 1, 0)
+			assert.ErrorIs(t, err, errs.ErrOverflow)
+		})
+	}
+
+	c := NewSharded(40, 4)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				Incr(ctx, c, "n", 1, 0)
+			}
+		}()
+	}
+	wg.Wait()
+	value, _, _ := c.Get(ctx, "n")
+	assert.Equal(t, int64(800), value)
+}
+
+// TestIncrNotAdmitted checks that an increment the admission filter keeps out of a full cache
+// is reported as not stored instead of returning a value that was never kept.
+func TestIncrNotAdmitted(t *testing.T) {
+	ctx := context.Background()
+	c := New(2, WithAdmission())
+	for _, key := range []string{"a", "b"} {
+		assert.NoError(t, c.Put(ctx, key, key, 0))
+		for range 5 {
+			c.Get(ctx, key)
+		}
+	}
+
+	for range 2 {
+		_, _, err := Incr(ctx, c, "limit", 1, time.Minute)
+		assert.ErrorIs(t, err, errs.ErrNotStored)
+		_, _, err = IncrFloat(ctx, c, "limit", 1, time.Minute)
+		assert.ErrorIs(t, err, errs.ErrNotStored)
+	}
+	_, _, err := c.Get(ctx, "limit")
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+}
+
+// TestBackedIncr verifies that increments start from the value in the backing store and go through to it.
+func TestBackedIncr(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(ctx, "n", 41, time.Now().Add(time.Hour)))
+	cache, err := Backed(New(2), store, StoreConfig{})
+	assert.NoError(t, err)
+
+	n, expiresAt, err := Incr(ctx, cache, "n", 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+	value, _, err := store.Load(ctx, "n")
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, value)
+	assert.NoError(t, cache.(io.Closer).Close())
+}
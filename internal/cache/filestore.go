@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"lru-cache/pkg/errs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStore is a Store keeping every key in its own JSON file in a directory.
+// It is meant as a reference implementation and for tests rather than for heavy loads.
+type FileStore struct {
+	dir string
+}
+
+// fileRecord is the content of a FileStore file. Key tells apart the keys whose file names are hashes,
+// it is missing from the files written before it was added.
+type fileRecord struct {
+	Key       string    `json:"key,omitempty"`
+	Value     any       `json:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// maxNameKey is the length of the longest key whose file is named after the key itself,
+// so that the base64-encoded name stays well within the 255 bytes most file systems allow.
+const maxNameKey = 180
+
+// path returns the file of key. Keys are base64-encoded so that any key makes a valid file name.
+// Longer keys are named by the hex SHA-256 of the key instead, with a prefix base64 never produces.
+func (f *FileStore) path(key string) string {
+	if len(key) > maxNameKey {
+		sum := sha256.Sum256([]byte(key))
+		return filepath.Join(f.dir, "~"+hex.EncodeToString(sum[:])+".json")
+	}
+	return filepath.Join(f.dir, base64.RawURLEncoding.EncodeToString([]byte(key))+".json")
+}
+
+// Load reads the value stored under key. Expired keys are deleted and reported as not found.
+func (f *FileStore) Load(ctx context.Context, key string) (value any, expiresAt time.Time, err error) {
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var rec fileRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, time.Time{}, err
+	}
+	if rec.Key != "" && rec.Key != key {
+		return nil, time.Time{}, errs.ErrNotFound
+	}
+	if !rec.ExpiresAt.IsZero() && rec.ExpiresAt.Before(time.Now()) {
+		return nil, time.Time{}, errors.Join(errs.ErrNotFound, f.Delete(ctx, key))
+	}
+	return rec.Value, rec.ExpiresAt, nil
+}
+
+// Save writes value under key, replacing the file atomically so that readers never see a partial write.
+func (f *FileStore) Save(ctx context.Context, key string, value any, expiresAt time.Time) error {
+	data, err := json.Marshal(fileRecord{Key: key, Value: value, ExpiresAt: expiresAt})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+// Delete removes the file of key.
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
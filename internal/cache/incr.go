@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"encoding/json"
+	"lru-cache/pkg/errs"
+	"math"
+	"strconv"
+)
+
+// addInt returns value incremented by delta as an int64, value being 0 if ok is false.
+// Floats, which encoding/json decodes every number into, are accepted as long as they hold an integer.
+func addInt(value any, ok bool, delta int64) (int64, error) {
+	if !ok {
+		return delta, nil
+	}
+	n, err := toInt(value)
+	if err != nil {
+		return 0, err
+	}
+	if delta > 0 && n > math.MaxInt64-delta || delta < 0 && n < math.MinInt64-delta {
+		return 0, errs.ErrOverflow
+	}
+	return n + delta, nil
+}
+
+// addFloat returns value incremented by delta as a float64, value being 0 if ok is false.
+func addFloat(value any, ok bool, delta float64) (float64, error) {
+	var f float64
+	if ok {
+		var err error
+		if f, err = toFloat(value); err != nil {
+			return 0, err
+		}
+	}
+	f += delta
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, errs.ErrOverflow
+	}
+	return f, nil
+}
+
+// toInt converts a numeric value to an int64.
+func toInt(value any) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return uintToInt(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return uintToInt(v)
+	case float32:
+		return floatToInt(float64(v))
+	case float64:
+		return floatToInt(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, errs.ErrNotNumeric
+		}
+		return floatToInt(f)
+	default:
+		return 0, errs.ErrNotNumeric
+	}
+}
+
+// toFloat converts a numeric value to a float64.
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return 0, errs.ErrNotNumeric
+		}
+		return f, nil
+	case uint:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	default:
+		n, err := toInt(value)
+		return float64(n), err
+	}
+}
+
+func uintToInt(v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, errs.ErrOverflow
+	}
+	return int64(v), nil
+}
+
+func floatToInt(v float64) (int64, error) {
+	if v != math.Trunc(v) {
+		return 0, errs.ErrNotInteger
+	}
+	// 2^63 is exactly representable, unlike math.MaxInt64.
+	if v < math.MinInt64 || v >= math.MaxInt64 {
+		return 0, errs.ErrOverflow
+	}
+	return int64(v), nil
+}
@@ -0,0 +1,417 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+	"sync"
+	"time"
+)
+
+// Store is a slower backing store the cache can front, see Backed.
+type Store interface {
+	// Load returns the value stored under key and its expiration time, zero if it never expires.
+	// Returns errs.ErrNotFound if there is no such key.
+	Load(ctx context.Context, key string) (value any, expiresAt time.Time, err error)
+	// Save stores value under key until expiresAt, forever if it is zero.
+	Save(ctx context.Context, key string, value any, expiresAt time.Time) error
+	// Delete removes key from the store. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// StoreConfig configures how a cache propagates writes to its backing store.
+type StoreConfig struct {
+	// WriteBehind queues writes and applies them to the store in the background instead of
+	// writing through synchronously. Successive writes of a key are coalesced into the last one.
+	WriteBehind bool
+	// FlushInterval is how often queued writes are applied, 1s if not positive.
+	FlushInterval time.Duration
+	// BatchSize is the number of queued keys that triggers a flush before the interval is over, 100 if not positive.
+	BatchSize int
+	// OnError is called with the writes that failed in the background. They are retried on the next flush
+	// unless the key has been written again in the meantime.
+	OnError func(key string, err error)
+}
+
+// Reloader is implemented by caches that can reload their entries from a backing store, see Backed.
+type Reloader interface {
+	// Reload reads key from the backing store with the TTL it has left. Returns errs.ErrNotFound if it is missing or expired.
+	Reload(ctx context.Context, key string) (value any, ttl time.Duration, err error)
+}
+
+// stored is a cache wired to a backing store. Writes go to both and reads load missing keys from the store.
+type stored struct {
+	*cache
+	store  Store
+	behind *writeBehind
+}
+
+// Backed wires c, created by New or NewSharded, to store: Put and Evict are propagated to the store,
+// synchronously or through a write-behind queue, and Get loads keys missing from the cache from it.
+// Concurrent loads of the same key are collapsed into one. EvictAll only clears the cache.
+// The returned cache must be closed to flush the queued writes.
+// Returns errs.ErrNotBackable if c was not created by New or NewSharded.
+func Backed(c ILRUCache, store Store, cfg StoreConfig) (ILRUCache, error) {
+	inner, ok := c.(*cache)
+	if !ok {
+		return nil, errs.ErrNotBackable
+	}
+	s := &stored{cache: inner, store: store}
+	if cfg.WriteBehind {
+		s.behind = newWriteBehind(store, cfg)
+	}
+	return s, nil
+}
+
+// Close flushes the queued writes, then stops the background goroutines of the cache.
+func (s *stored) Close() error {
+	var err error
+	if s.behind != nil {
+		err = s.behind.close()
+	}
+	return errors.Join(err, s.cache.Close())
+}
+
+// Put stores data in the cache and in the backing store. When writing through,
+// the cache is only updated once the store has accepted the value.
+func (s *stored) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	if s.behind != nil {
+		s.behind.enqueue(key, write{value: value, expiresAt: expiresAt})
+	} else if err := s.store.Save(ctx, key, value, expiresAt); err != nil {
+		return err
+	}
+	return s.cache.Put(ctx, key, value, ttl)
+}
+
+// Get retrieves data from the cache by key, loading it from the backing store on a miss.
+func (s *stored) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
+	value, err = s.lru.GetOrLoad(ctx, key, func(ctx context.Context) (any, time.Duration, error) {
+		return s.load(ctx, key)
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	_, expiresAt, _ = s.lru.Peek(key)
+	return value, expiresAt, nil
+}
+
+// GetItem retrieves an entry from the cache by key along with the state of its TTL,
+// loading it from the backing store on a miss.
+func (s *stored) GetItem(ctx context.Context, key string) (item lru.Item[any], err error) {
+	item, err = s.cache.GetItem(ctx, key)
+	if !errors.Is(err, errs.ErrNotFound) {
+		return item, err
+	}
+	item.Value, item.ExpiresAt, err = s.Get(ctx, key)
+	if err != nil {
+		return item, err
+	}
+	if peeked, ok := s.lru.PeekItem(key); ok {
+		item.Version = peeked.Version
+	}
+	return item, nil
+}
+
+// Reload reads key from the backing store, through the write-behind queue, with the TTL it has left.
+// It is the loader to register with lru.WithLoader for the refresh-ahead and stale grace of the cache.
+func (s *stored) Reload(ctx context.Context, key string) (value any, ttl time.Duration, err error) {
+	return s.load(ctx, key)
+}
+
+// load reads key from the write-behind queue, or from the store if it has no pending write.
+func (s *stored) load(ctx context.Context, key string) (any, time.Duration, error) {
+	var (
+		value     any
+		expiresAt time.Time
+	)
+	w, ok := s.behind.pending(key)
+	switch {
+	case ok && w.delete:
+		return nil, 0, errs.ErrNotFound
+	case ok:
+		value, expiresAt = w.value, w.expiresAt
+	default:
+		var err error
+		if value, expiresAt, err = s.store.Load(ctx, key); err != nil {
+			return nil, 0, err
+		}
+	}
+	if expiresAt.IsZero() {
+		return value, 0, nil
+	}
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil, 0, errs.ErrNotFound
+	}
+	return value, ttl, nil
+}
+
+// Evict removes data by key from the cache and from the backing store.
+// The key is deleted from the store even if it was not cached.
+func (s *stored) Evict(ctx context.Context, key string) (value interface{}, err error) {
+	value, err = s.cache.Evict(ctx, key)
+	if s.behind != nil {
+		s.behind.enqueue(key, write{delete: true})
+	} else if delErr := s.store.Delete(ctx, key); delErr != nil {
+		return nil, delErr
+	}
+	return value, err
+}
+
+// GetMany retrieves data from the cache for every key in a single batch,
+// then loads the keys missing from the cache from the backing store one by one.
+func (s *stored) GetMany(ctx context.Context, keys []string) (results []Result, err error) {
+	results, _ = s.cache.GetMany(ctx, keys)
+	for i, key := range keys {
+		if results[i].Err == nil {
+			continue
+		}
+		item, err := s.GetItem(ctx, key)
+		if err != nil && !errors.Is(err, errs.ErrNotFound) {
+			return nil, err
+		}
+		results[i] = Result{Item: item, Err: err}
+	}
+	return results, nil
+}
+
+// PutMany stores data in the backing store, then in the cache in a single batch.
+// When writing through, the cache is left unchanged if the store fails.
+// stored[i] reports whether values[i] was admitted into the cache; it is in the store either way.
+func (s *stored) PutMany(ctx context.Context, keys []string, values []any, ttls []time.Duration) (stored []bool, err error) {
+	for i, key := range keys {
+		var expiresAt time.Time
+		if ttls[i] > 0 {
+			expiresAt = time.Now().Add(ttls[i])
+		}
+		if err := s.save(ctx, key, values[i], expiresAt); err != nil {
+			return nil, err
+		}
+	}
+	return s.cache.PutMany(ctx, keys, values, ttls)
+}
+
+// EvictMany removes data for every key from the cache in a single batch and from the backing store.
+func (s *stored) EvictMany(ctx context.Context, keys []string) (results []Result, err error) {
+	results, _ = s.cache.EvictMany(ctx, keys)
+	for _, key := range keys {
+		if s.behind != nil {
+			s.behind.enqueue(key, write{delete: true})
+		} else if err := s.store.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// PutIf stores data in the cache if the condition of mode holds, then in the backing store if it was stored.
+// Only the cache is checked for the condition.
+func (s *stored) PutIf(ctx context.Context, key string, value any, ttl time.Duration, mode lru.Mode) (bool, error) {
+	ok, _ := s.cache.PutIf(ctx, key, value, ttl, mode)
+	if !ok {
+		return false, nil
+	}
+	_, expiresAt, _ := s.lru.Peek(key)
+	return true, s.save(ctx, key, value, expiresAt)
+}
+
+// PutIfVersion stores data in the cache if the live entry stored under key has the given version,
+// then in the backing store if it was stored. Only the cache is checked for the version.
+func (s *stored) PutIfVersion(ctx context.Context, key string, value any, ttl time.Duration, version uint64) (uint64, bool, error) {
+	current, ok, _ := s.cache.PutIfVersion(ctx, key, value, ttl, version)
+	if !ok {
+		return current, false, nil
+	}
+	_, expiresAt, _ := s.lru.Peek(key)
+	return current, true, s.save(ctx, key, value, expiresAt)
+}
+
+// Incr adds delta to the integer stored under key in the cache, loading it from the backing store
+// if it is missing, then saves the new value to the store. The increment is atomic in the cache only.
+func (s *stored) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (value int64, expiresAt time.Time, err error) {
+	if _, _, err = s.Get(ctx, key); err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return 0, time.Time{}, err
+	}
+	if value, expiresAt, err = s.cache.Incr(ctx, key, delta, ttl); err != nil {
+		return 0, time.Time{}, err
+	}
+	return value, expiresAt, s.save(ctx, key, value, expiresAt)
+}
+
+// IncrFloat adds delta to the number stored under key like Incr, as a float.
+func (s *stored) IncrFloat(ctx context.Context, key string, delta float64, ttl time.Duration) (value float64, expiresAt time.Time, err error) {
+	if _, _, err = s.Get(ctx, key); err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return 0, time.Time{}, err
+	}
+	if value, expiresAt, err = s.cache.IncrFloat(ctx, key, delta, ttl); err != nil {
+		return 0, time.Time{}, err
+	}
+	return value, expiresAt, s.save(ctx, key, value, expiresAt)
+}
+
+// Expire sets the TTL of the entry stored under key in the cache and in the backing store.
+func (s *stored) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if err := s.cache.Expire(ctx, key, ttl); err != nil {
+		return err
+	}
+	value, expiresAt, ok := s.lru.Peek(key)
+	if !ok {
+		return errs.ErrNotFound
+	}
+	return s.save(ctx, key, value, expiresAt)
+}
+
+// save propagates a stored value to the backing store.
+func (s *stored) save(ctx context.Context, key string, value any, expiresAt time.Time) error {
+	if s.behind != nil {
+		s.behind.enqueue(key, write{value: value, expiresAt: expiresAt})
+		return nil
+	}
+	return s.store.Save(ctx, key, value, expiresAt)
+}
+
+// write is a queued change of a key in the backing store.
+type write struct {
+	value     any
+	expiresAt time.Time
+	delete    bool
+}
+
+// writeBehind applies writes to a store in the background, keeping only the last write of every key.
+type writeBehind struct {
+	store    Store
+	interval time.Duration
+	batch    int
+	onError  func(key string, err error)
+
+	mu       sync.Mutex
+	queued   map[string]write
+	flushing map[string]write
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newWriteBehind(store Store, cfg StoreConfig) *writeBehind {
+	w := &writeBehind{
+		store:    store,
+		interval: cfg.FlushInterval,
+		batch:    cfg.BatchSize,
+		onError:  cfg.OnError,
+		queued:   make(map[string]write),
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if w.interval <= 0 {
+		w.interval = time.Second
+	}
+	if w.batch <= 0 {
+		w.batch = 100
+	}
+	if w.onError == nil {
+		w.onError = func(string, error) {}
+	}
+	go w.loop()
+	return w
+}
+
+func (w *writeBehind) enqueue(key string, wr write) {
+	w.mu.Lock()
+	w.queued[key] = wr
+	full := len(w.queued) >= w.batch
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// pending returns the write of key that has not reached the store yet, if any.
+// It is safe to call on a nil queue.
+func (w *writeBehind) pending(key string) (write, bool) {
+	if w == nil {
+		return write{}, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if wr, ok := w.queued[key]; ok {
+		return wr, true
+	}
+	wr, ok := w.flushing[key]
+	return wr, ok
+}
+
+func (w *writeBehind) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			w.flush()
+			return
+		case <-ticker.C:
+		case <-w.kick:
+		}
+		w.flush()
+	}
+}
+
+// flush applies the queued writes. Failed writes are queued again unless the key was written since.
+func (w *writeBehind) flush() error {
+	w.mu.Lock()
+	batch := w.queued
+	w.queued, w.flushing = make(map[string]write), batch
+	w.mu.Unlock()
+
+	var failures []error
+	failed := make(map[string]write)
+	for key, wr := range batch {
+		var err error
+		if wr.delete {
+			err = w.store.Delete(context.Background(), key)
+		} else {
+			err = w.store.Save(context.Background(), key, wr.value, wr.expiresAt)
+		}
+		if err != nil {
+			failed[key] = wr
+			failures = append(failures, err)
+			w.onError(key, err)
+		}
+	}
+
+	w.mu.Lock()
+	for key, wr := range failed {
+		if _, ok := w.queued[key]; !ok {
+			w.queued[key] = wr
+		}
+	}
+	w.flushing = nil
+	w.mu.Unlock()
+	return errors.Join(failures...)
+}
+
+// close stops the background flushes and applies the writes still queued, once more for failed ones.
+func (w *writeBehind) close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.stop)
+		<-w.done
+		err = w.flush()
+	})
+	return err
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"encoding/json"
+	"lru-cache/pkg/lru"
+)
+
+// Approximate in-memory sizes, in bytes, of the values produced by decoding JSON into interface{}.
+const (
+	interfaceSize = 16
+	stringHeader  = 16
+	sliceHeader   = 24
+	mapHeader     = 48
+	numberSize    = 8
+)
+
+// WithMaxBytes returns an option bounding the cache by the estimated memory taken by its keys and values
+// instead of their number. See lru.WithMaxWeight.
+func WithMaxBytes(max int64) lru.Option[string, any] {
+	return lru.WithMaxWeight(max, Weigh)
+}
+
+// Weigh estimates the memory taken by an entry in bytes, from the types encoding/json decodes values into.
+func Weigh(key string, value any) int64 {
+	return stringHeader + int64(len(key)) + interfaceSize + valueSize(value)
+}
+
+func valueSize(value any) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case string:
+		return stringHeader + int64(len(v))
+	case json.Number:
+		return stringHeader + int64(len(v))
+	case []any:
+		size := int64(sliceHeader)
+		for _, item := range v {
+			size += interfaceSize + valueSize(item)
+		}
+		return size
+	case map[string]any:
+		size := int64(mapHeader)
+		for k, item := range v {
+			size += stringHeader + int64(len(k)) + interfaceSize + valueSize(item)
+		}
+		return size
+	default:
+		return numberSize
+	}
+}
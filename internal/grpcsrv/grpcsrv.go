@@ -0,0 +1,275 @@
+// Package grpcsrv serves a cache over gRPC with the service defined in lrupb, mirroring the HTTP API.
+// The context of every call, carrying its deadline and cancellation, is passed on to the cache.
+package grpcsrv
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"lru-cache/internal/cache"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+	"lru-cache/pkg/lrupb"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// scanPage is the number of entries GetAll reads from the cache at a time.
+const scanPage = 1000
+
+type service struct {
+	lrupb.UnimplementedCacheServer
+	storage    cache.ILRUCache
+	logger     *slog.Logger
+	defaultTTL time.Duration
+}
+
+// New creates a gRPC server serving the storage. Values put without a TTL expire after defaultTTL.
+func New(storage cache.ILRUCache, logger *slog.Logger, defaultTTL time.Duration, opts ...grpc.ServerOption) *grpc.Server {
+	s := &service{storage: storage, logger: logger, defaultTTL: defaultTTL}
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.logUnary), grpc.ChainStreamInterceptor(s.logStream))
+	server := grpc.NewServer(opts...)
+	lrupb.RegisterCacheServer(server, s)
+	return server
+}
+
+func (s *service) logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.logger.Debug("handled call", slog.String("method", info.FullMethod), slog.String("code", status.Code(err).String()), slog.Duration("handling time", time.Since(start)))
+	return resp, err
+}
+
+func (s *service) logStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.logger.Debug("handled call", slog.String("method", info.FullMethod), slog.String("code", status.Code(err).String()), slog.Duration("handling time", time.Since(start)))
+	return err
+}
+
+// status converts an error of the cache to a gRPC status error, logging unexpected ones.
+func (s *service) status(method string, err error) error {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	s.logger.Warn("Something went wrong in a gRPC call", slog.String("method", method), slog.Any("error", err))
+	return status.Error(codes.Internal, "something went wrong")
+}
+
+// Put stores a value under a key.
+func (s *service) Put(ctx context.Context, req *lrupb.PutRequest) (*lrupb.PutResponse, error) {
+	if err := s.put(ctx, req); err != nil {
+		return nil, s.status("Put", err)
+	}
+	return &lrupb.PutResponse{}, nil
+}
+
+func (s *service) put(ctx context.Context, req *lrupb.PutRequest) error {
+	value, ttl, err := s.decode(req)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.storage.Put(ctx, req.GetKey(), value, ttl)
+}
+
+// decode returns the value and the TTL of a put request.
+func (s *service) decode(req *lrupb.PutRequest) (value any, ttl time.Duration, err error) {
+	value, err = fromValue(req.GetValue())
+	if err != nil {
+		return nil, 0, err
+	}
+	ttl = s.defaultTTL
+	if req.GetTtl() != nil {
+		if err := req.GetTtl().CheckValid(); err != nil {
+			return nil, 0, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if d := req.GetTtl().AsDuration(); d > 0 {
+			ttl = d
+		}
+	}
+	return value, ttl, nil
+}
+
+// Get retrieves the value stored under a key.
+func (s *service) Get(ctx context.Context, req *lrupb.GetRequest) (*lrupb.GetResponse, error) {
+	entry, err := s.get(ctx, req.GetKey())
+	if err != nil {
+		return nil, s.status("Get", err)
+	}
+	return &lrupb.GetResponse{Entry: entry}, nil
+}
+
+func (s *service) get(ctx context.Context, key string) (*lrupb.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	value, expiresAt, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return entry(key, value, expiresAt)
+}
+
+// entry converts a cache entry to an Entry.
+func entry(key string, value any, expiresAt time.Time) (*lrupb.Entry, error) {
+	v, err := toValue(value)
+	if err != nil {
+		return nil, err
+	}
+	e := &lrupb.Entry{Key: key, Value: v}
+	if !expiresAt.IsZero() {
+		e.ExpiresAt = timestamppb.New(expiresAt)
+	}
+	return e, nil
+}
+
+// GetAll streams every entry of the cache, from the least to the most recently used one.
+// If the cache can be scanned, entries are read and sent a page at a time, with their expiration times;
+// otherwise the whole cache is read at once and sent without them.
+func (s *service) GetAll(req *lrupb.GetAllRequest, stream lrupb.Cache_GetAllServer) error {
+	ctx := stream.Context()
+	scanner, ok := s.storage.(cache.Scanner)
+	if !ok {
+		keys, values, err := s.storage.GetAll(ctx)
+		if err != nil && !errors.Is(err, errs.ErrCacheIsEmpty) {
+			return s.status("GetAll", err)
+		}
+		for i, key := range keys {
+			if err := s.send(stream, key, values[i], time.Time{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	cursor := ""
+	for {
+		entries, next, err := scanner.Scan(ctx, cursor, scanPage, lru.LeastRecent, nil)
+		if err != nil {
+			return s.status("GetAll", err)
+		}
+		for _, e := range entries {
+			if err := s.send(stream, e.Key, e.Value, e.ExpiresAt); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// send sends an entry on the stream of GetAll.
+func (s *service) send(stream lrupb.Cache_GetAllServer, key string, value any, expiresAt time.Time) error {
+	e, err := entry(key, value, expiresAt)
+	if err != nil {
+		return s.status("GetAll", err)
+	}
+	return stream.Send(e)
+}
+
+// Evict removes a key and returns its value.
+func (s *service) Evict(ctx context.Context, req *lrupb.EvictRequest) (*lrupb.EvictResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, s.status("Evict", err)
+	}
+	value, err := s.storage.Evict(ctx, req.GetKey())
+	if errors.Is(err, errs.ErrCacheIsEmpty) {
+		err = errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, s.status("Evict", err)
+	}
+	v, err := toValue(value)
+	if err != nil {
+		return nil, s.status("Evict", err)
+	}
+	return &lrupb.EvictResponse{Value: v}, nil
+}
+
+// EvictAll removes every key. Evicting all keys of an empty cache is not an error.
+func (s *service) EvictAll(ctx context.Context, req *lrupb.EvictAllRequest) (*lrupb.EvictAllResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, s.status("EvictAll", err)
+	}
+	if err := s.storage.EvictAll(ctx); err != nil && !errors.Is(err, errs.ErrCacheIsEmpty) {
+		return nil, s.status("EvictAll", err)
+	}
+	return &lrupb.EvictAllResponse{}, nil
+}
+
+// PutMany stores several values in order, as a single batch. Nothing is stored if an entry is invalid.
+func (s *service) PutMany(ctx context.Context, req *lrupb.PutManyRequest) (*lrupb.PutManyResponse, error) {
+	entries := req.GetEntries()
+	keys := make([]string, len(entries))
+	values := make([]any, len(entries))
+	ttls := make([]time.Duration, len(entries))
+	for i, put := range entries {
+		var err error
+		if values[i], ttls[i], err = s.decode(put); err != nil {
+			return nil, s.status("PutMany", err)
+		}
+		keys[i] = put.GetKey()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, s.status("PutMany", err)
+	}
+	if _, err := cache.PutMany(ctx, s.storage, keys, values, ttls); err != nil {
+		return nil, s.status("PutMany", err)
+	}
+	return &lrupb.PutManyResponse{}, nil
+}
+
+// GetMany retrieves the values of several keys as a single batch, leaving out the missing ones.
+func (s *service) GetMany(ctx context.Context, req *lrupb.GetManyRequest) (*lrupb.GetManyResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, s.status("GetMany", err)
+	}
+	results, err := cache.GetMany(ctx, s.storage, req.GetKeys())
+	if err != nil {
+		return nil, s.status("GetMany", err)
+	}
+	resp := &lrupb.GetManyResponse{}
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		e, err := entry(req.GetKeys()[i], res.Item.Value, res.Item.ExpiresAt)
+		if err != nil {
+			return nil, s.status("GetMany", err)
+		}
+		resp.Entries = append(resp.Entries, e)
+	}
+	return resp, nil
+}
+
+// EvictMany removes several keys as a single batch and returns the ones that were found.
+func (s *service) EvictMany(ctx context.Context, req *lrupb.EvictManyRequest) (*lrupb.EvictManyResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, s.status("EvictMany", err)
+	}
+	results, err := cache.EvictMany(ctx, s.storage, req.GetKeys())
+	if err != nil {
+		return nil, s.status("EvictMany", err)
+	}
+	resp := &lrupb.EvictManyResponse{}
+	for i, res := range results {
+		if res.Err == nil {
+			resp.Evicted = append(resp.Evicted, req.GetKeys()[i])
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,178 @@
+package grpcsrv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"lru-cache/internal/cache"
+	"lru-cache/pkg/lrupb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// client serves c in memory and returns a client connected to it.
+func client(t *testing.T, c cache.ILRUCache) lrupb.CacheClient {
+	l := bufconn.Listen(1 << 20)
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Minute)
+	go server.Serve(l)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return lrupb.NewCacheClient(conn)
+}
+
+func str(s string) *lrupb.Value { return &lrupb.Value{Kind: &lrupb.Value_String_{String_: s}} }
+
+// TestService checks every call of the service, including the conversion of values of every kind.
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	c := client(t, cache.New(10))
+
+	values := []*lrupb.Value{
+		str("a"),
+		{Kind: &lrupb.Value_Number{Number: 1.5}},
+		{Kind: &lrupb.Value_Bool{Bool: true}},
+		{Kind: &lrupb.Value_Bytes{Bytes: []byte{0xff, 0x00}}},
+		{Kind: &lrupb.Value_Json{Json: `{"a":[1,null]}`}},
+	}
+	keys := []string{"string", "number", "bool", "bytes", "json"}
+	for i, key := range keys {
+		_, err := c.Put(ctx, &lrupb.PutRequest{Key: key, Value: values[i]})
+		assert.NoError(t, err)
+	}
+	for i, key := range keys {
+		resp, err := c.Get(ctx, &lrupb.GetRequest{Key: key})
+		assert.NoError(t, err)
+		assert.Equal(t, key, resp.GetEntry().GetKey())
+		assert.Equal(t, values[i].String(), resp.GetEntry().GetValue().String())
+		assert.WithinDuration(t, time.Now().Add(time.Minute), resp.GetEntry().GetExpiresAt().AsTime(), time.Second)
+	}
+
+	_, err := c.Put(ctx, &lrupb.PutRequest{Key: "x"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = c.Put(ctx, &lrupb.PutRequest{Key: "x", Value: &lrupb.Value{Kind: &lrupb.Value_Json{Json: "{"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = c.Get(ctx, &lrupb.GetRequest{Key: "x"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := c.GetAll(ctx, &lrupb.GetAllRequest{})
+	assert.NoError(t, err)
+	var streamed []string
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		streamed = append(streamed, e.GetKey())
+	}
+	assert.Equal(t, keys, streamed)
+
+	evicted, err := c.Evict(ctx, &lrupb.EvictRequest{Key: "string"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", evicted.GetValue().GetString_())
+	_, err = c.Evict(ctx, &lrupb.EvictRequest{Key: "string"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = c.EvictAll(ctx, &lrupb.EvictAllRequest{})
+	assert.NoError(t, err)
+	_, err = c.EvictAll(ctx, &lrupb.EvictAllRequest{})
+	assert.NoError(t, err)
+}
+
+// TestBatches checks the batch calls and that puts without a TTL use the default one.
+func TestBatches(t *testing.T) {
+	ctx := context.Background()
+	c := client(t, cache.New(10))
+
+	_, err := c.PutMany(ctx, &lrupb.PutManyRequest{Entries: []*lrupb.PutRequest{
+		{Key: "a", Value: str("1"), Ttl: durationpb.New(time.Hour)},
+		{Key: "b", Value: str("2")},
+		{Key: "c", Value: str("3")},
+	}})
+	assert.NoError(t, err)
+
+	got, err := c.GetMany(ctx, &lrupb.GetManyRequest{Keys: []string{"a", "missing", "b"}})
+	assert.NoError(t, err)
+	assert.Len(t, got.GetEntries(), 2)
+	assert.Equal(t, "a", got.GetEntries()[0].GetKey())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), got.GetEntries()[0].GetExpiresAt().AsTime(), time.Second)
+	assert.Equal(t, "2", got.GetEntries()[1].GetValue().GetString_())
+	assert.WithinDuration(t, time.Now().Add(time.Minute), got.GetEntries()[1].GetExpiresAt().AsTime(), time.Second)
+
+	evicted, err := c.EvictMany(ctx, &lrupb.EvictManyRequest{Keys: []string{"a", "missing", "c"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, evicted.GetEvicted())
+}
+
+// TestDeadline checks that a call whose deadline has passed is not applied to the cache.
+func TestDeadline(t *testing.T) {
+	storage := cache.New(10)
+	c := client(t, storage)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	time.Sleep(5 * time.Millisecond)
+	_, err := c.Put(ctx, &lrupb.PutRequest{Key: "a", Value: str("1")})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	_, _, err = storage.Get(context.Background(), "a")
+	assert.Error(t, err)
+}
+
+// TestCounters checks that the integers stored by Incr are sent as numbers rather than JSON.
+func TestCounters(t *testing.T) {
+	ctx := context.Background()
+	storage := cache.New(10)
+	c := client(t, storage)
+
+	_, _, err := cache.Incr(ctx, storage, "hits", 42, time.Hour)
+	assert.NoError(t, err)
+	resp, err := c.Get(ctx, &lrupb.GetRequest{Key: "hits"})
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, resp.GetEntry().GetValue().GetNumber())
+}
+
+// TestGetAllPages checks that GetAll streams caches larger than a page completely and in order.
+func TestGetAllPages(t *testing.T) {
+	ctx := context.Background()
+	storage := cache.New(3 * scanPage)
+	c := client(t, storage)
+
+	var keys []string
+	for i := range 2*scanPage + 10 {
+		key := fmt.Sprintf("key%d", i)
+		assert.NoError(t, storage.Put(ctx, key, float64(i), time.Hour))
+		keys = append(keys, key)
+	}
+
+	stream, err := c.GetAll(ctx, &lrupb.GetAllRequest{})
+	assert.NoError(t, err)
+	var streamed []string
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), e.GetExpiresAt().AsTime(), time.Second)
+		streamed = append(streamed, e.GetKey())
+	}
+	assert.Equal(t, keys, streamed)
+}
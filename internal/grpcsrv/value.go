@@ -0,0 +1,83 @@
+package grpcsrv
+
+import (
+	"encoding/json"
+	"lru-cache/pkg/lrupb"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toValue converts a cache value to a Value. Strings, numbers and booleans keep their type, strings that
+// are not valid UTF-8, such as binary data stored over memcached, are sent as bytes and any other value as JSON.
+// Integers, such as the counters kept by Incr, are sent as numbers too.
+func toValue(value any) (*lrupb.Value, error) {
+	switch v := value.(type) {
+	case string:
+		if !utf8.ValidString(v) {
+			return &lrupb.Value{Kind: &lrupb.Value_Bytes{Bytes: []byte(v)}}, nil
+		}
+		return &lrupb.Value{Kind: &lrupb.Value_String_{String_: v}}, nil
+	case float64:
+		return number(v), nil
+	case float32:
+		return number(float64(v)), nil
+	case int:
+		return number(float64(v)), nil
+	case int8:
+		return number(float64(v)), nil
+	case int16:
+		return number(float64(v)), nil
+	case int32:
+		return number(float64(v)), nil
+	case int64:
+		return number(float64(v)), nil
+	case uint:
+		return number(float64(v)), nil
+	case uint8:
+		return number(float64(v)), nil
+	case uint16:
+		return number(float64(v)), nil
+	case uint32:
+		return number(float64(v)), nil
+	case uint64:
+		return number(float64(v)), nil
+	case bool:
+		return &lrupb.Value{Kind: &lrupb.Value_Bool{Bool: v}}, nil
+	case []byte:
+		return &lrupb.Value{Kind: &lrupb.Value_Bytes{Bytes: v}}, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &lrupb.Value{Kind: &lrupb.Value_Json{Json: string(data)}}, nil
+}
+
+func number(f float64) *lrupb.Value {
+	return &lrupb.Value{Kind: &lrupb.Value_Number{Number: f}}
+}
+
+// fromValue converts a Value to a cache value. Bytes are stored as a string, which the other APIs
+// read as they are, and JSON is decoded into the same types as the values put through the HTTP API.
+// Returns an InvalidArgument status error if the value is unset or not valid JSON.
+func fromValue(value *lrupb.Value) (any, error) {
+	switch v := value.GetKind().(type) {
+	case *lrupb.Value_String_:
+		return v.String_, nil
+	case *lrupb.Value_Number:
+		return v.Number, nil
+	case *lrupb.Value_Bool:
+		return v.Bool, nil
+	case *lrupb.Value_Bytes:
+		return string(v.Bytes), nil
+	case *lrupb.Value_Json:
+		var decoded any
+		if err := json.Unmarshal([]byte(v.Json), &decoded); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid json value: %v", err)
+		}
+		return decoded, nil
+	}
+	return nil, status.Error(codes.InvalidArgument, "value is required")
+}
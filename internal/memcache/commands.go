@@ -0,0 +1,445 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"lru-cache/internal/cache"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+	"os"
+	"strconv"
+	"time"
+)
+
+// version is the memcached version reported to clients.
+const version = "1.6.21"
+
+// command is a command handler. It reads the data block of the command from r, if any, and writes its reply to w.
+type command func(s *Server, ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get":       (*Server).get,
+		"gets":      (*Server).gets,
+		"set":       storage(0),
+		"add":       storage(lru.IfAbsent),
+		"replace":   storage(lru.IfPresent),
+		"cas":       (*Server).cas,
+		"delete":    (*Server).delete,
+		"touch":     (*Server).touch,
+		"flush_all": (*Server).flushAll,
+		"stats":     (*Server).statsCmd,
+		"version":   (*Server).version,
+		"verbosity": (*Server).verbosity,
+		"mg":        (*Server).metaGet,
+		"ms":        (*Server).metaSet,
+		"md":        (*Server).metaDelete,
+		"mn":        (*Server).metaNoop,
+	}
+}
+
+// clientError is an error in the command sent by the client, replied with CLIENT_ERROR. Other errors
+// come from the storage, they are logged and replied with SERVER_ERROR.
+type clientError string
+
+func (e clientError) Error() string { return string(e) }
+
+const (
+	errFormat   clientError = "bad command line format"
+	errBadChunk clientError = "bad data chunk"
+	errFlag     clientError = "invalid flag"
+)
+
+// errNoCAS is returned when a CAS unique is checked against a storage that does not version its entries.
+var errNoCAS = errors.New("cas is not supported by the storage")
+
+// execute runs a command and writes its reply. Returns true if the connection must be closed.
+func (s *Server) execute(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) (quit bool) {
+	if len(args) == 0 {
+		w.WriteString("ERROR\r\n")
+		return false
+	}
+	name := string(args[0])
+	if name == "quit" {
+		return true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		w.WriteString("ERROR\r\n")
+		return false
+	}
+	err := cmd(s, ctx, r, w, args)
+	var (
+		conn   connError
+		client clientError
+	)
+	switch {
+	case err == nil:
+	case errors.As(err, &conn):
+		return true
+	case errors.As(err, &client):
+		w.WriteString("CLIENT_ERROR " + string(client) + "\r\n")
+		// The rest of a bad data block cannot be told apart from the next command.
+		return client == errBadChunk
+	default:
+		s.logger.Warn("Something went wrong in a memcached command", slog.String("command", name), slog.Any("error", err))
+		w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+	}
+	return false
+}
+
+// noreply tells whether the last argument asks not to reply.
+func noreply(args [][]byte) bool {
+	return string(args[len(args)-1]) == "noreply"
+}
+
+func (s *Server) get(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	return s.retrieve(ctx, w, args[1:], false)
+}
+
+// gets retrieves keys like get along with their CAS unique, the version of their entry or 0 if the storage
+// does not version its entries.
+func (s *Server) gets(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	return s.retrieve(ctx, w, args[1:], true)
+}
+
+func (s *Server) retrieve(ctx context.Context, w *bufio.Writer, keys [][]byte, withCAS bool) error {
+	if len(keys) == 0 {
+		return errFormat
+	}
+	for _, key := range keys {
+		if !validKey(key) {
+			return errFormat
+		}
+	}
+	for _, key := range keys {
+		s.stats.cmdGet.Add(1)
+		item, err := cache.GetItem(ctx, s.storage, string(key))
+		if errors.Is(err, errs.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		data, flags, err := decode(item.Value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "VALUE %s %d %d", key, flags, len(data))
+		if withCAS {
+			fmt.Fprintf(w, " %d", item.Version)
+		}
+		w.WriteString("\r\n")
+		w.Write(data)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("END\r\n")
+	return nil
+}
+
+// storage returns the handler of a storage command storing if the condition of mode holds, always if it is zero:
+//
+//	<command> <key> <flags> <exptime> <bytes> [noreply]\r\n<data>\r\n
+func storage(mode lru.Mode) command {
+	return func(s *Server, ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+		return s.storageCommand(ctx, r, w, args, mode, false)
+	}
+}
+
+// cas stores a key if its entry was not changed since it was read with gets:
+//
+//	cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]\r\n<data>\r\n
+func (s *Server) cas(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	return s.storageCommand(ctx, r, w, args, 0, true)
+}
+
+// storageCommand runs a storage command, checking the CAS unique of the entry after the length of the data if withCAS is set.
+func (s *Server) storageCommand(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte, mode lru.Mode, withCAS bool) error {
+	extra := 0
+	if withCAS {
+		extra = 1
+	}
+	if len(args) != 5+extra && len(args) != 6+extra {
+		return errFormat
+	}
+	n, err := strconv.Atoi(string(args[4]))
+	if err != nil || n < 0 {
+		return errFormat
+	}
+	if n > maxValue {
+		if _, err := r.Discard(n + 2); err != nil {
+			return connError{err}
+		}
+		w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return nil
+	}
+	data, err := readData(r, n)
+	if err != nil {
+		return err
+	}
+	flags, flagsErr := strconv.ParseUint(string(args[2]), 10, 32)
+	exptime, exptimeErr := strconv.ParseInt(string(args[3]), 10, 64)
+	if flagsErr != nil || exptimeErr != nil || !validKey(args[1]) {
+		return errFormat
+	}
+
+	s.stats.cmdSet.Add(1)
+	key, value := string(args[1]), encode(data, uint32(flags))
+	reply := "STORED"
+	if withCAS {
+		version, err := strconv.ParseUint(string(args[5]), 10, 64)
+		if err != nil {
+			return errFormat
+		}
+		stored, found, err := s.swap(ctx, key, value, exptime, version)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !found:
+			reply = "NOT_FOUND"
+		case !stored:
+			reply = "EXISTS"
+		}
+	} else {
+		stored, err := s.store(ctx, key, value, exptime, mode)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			reply = "NOT_STORED"
+		}
+	}
+	if !noreply(args) {
+		w.WriteString(reply + "\r\n")
+	}
+	return nil
+}
+
+// store stores value under key until exptime if the condition of mode holds, always if it is zero.
+// Returns whether the value was stored.
+func (s *Server) store(ctx context.Context, key string, value any, exptime int64, mode lru.Mode) (bool, error) {
+	ttl, live := expiry(exptime)
+	if live && mode == 0 {
+		return true, s.storage.Put(ctx, key, value, ttl)
+	}
+	if live {
+		return cache.PutIf(ctx, s.storage, key, value, ttl, mode)
+	}
+
+	// The value would expire at once, so storing it only removes the key.
+	_, _, err := cache.Peek(ctx, s.storage, key)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return false, err
+	}
+	if mode != 0 && (err == nil) != (mode == lru.IfPresent) {
+		return false, nil
+	}
+	_, err = s.evict(ctx, key)
+	return err == nil, err
+}
+
+// swap stores value under key until exptime if its entry has the given version, its CAS unique.
+// Returns whether the value was stored and whether the key was found.
+func (s *Server) swap(ctx context.Context, key string, value any, exptime int64, version uint64) (stored, found bool, err error) {
+	versioner, ok := s.storage.(cache.Versioner)
+	if !ok {
+		return false, false, errNoCAS
+	}
+	ttl, live := expiry(exptime)
+	if live {
+		current, stored, err := versioner.PutIfVersion(ctx, key, value, ttl, version)
+		return stored, stored || current != 0, err
+	}
+
+	// The value would expire at once, so storing it only removes the key.
+	current, err := versioner.Version(ctx, key)
+	if errors.Is(err, errs.ErrNotFound) {
+		return false, false, nil
+	}
+	if err != nil || current != version {
+		return false, true, err
+	}
+	_, err = s.evict(ctx, key)
+	return err == nil, true, err
+}
+
+// evict removes key from the storage. Returns whether it was there.
+func (s *Server) evict(ctx context.Context, key string) (bool, error) {
+	_, err := s.storage.Evict(ctx, key)
+	if errors.Is(err, errs.ErrNotFound) || errors.Is(err, errs.ErrCacheIsEmpty) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// delete removes a key:
+//
+//	delete <key> [0] [noreply]
+func (s *Server) delete(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	errUsage := clientError("bad command line format.  Usage: delete <key> [noreply]")
+	if len(args) < 2 {
+		return errUsage
+	}
+	rest := args[2:]
+	if len(rest) > 0 && noreply(args) {
+		rest = rest[:len(rest)-1]
+	}
+	if len(rest) > 1 || (len(rest) == 1 && string(rest[0]) != "0") || !validKey(args[1]) {
+		return errUsage
+	}
+	deleted, err := s.evict(ctx, string(args[1]))
+	if err != nil {
+		return err
+	}
+	if deleted {
+		s.stats.deleteHits.Add(1)
+	} else {
+		s.stats.deleteMiss.Add(1)
+	}
+	if noreply(args) {
+		return nil
+	}
+	if deleted {
+		w.WriteString("DELETED\r\n")
+	} else {
+		w.WriteString("NOT_FOUND\r\n")
+	}
+	return nil
+}
+
+// touch changes the expiration time of a key:
+//
+//	touch <key> <exptime> [noreply]
+func (s *Server) touch(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	if len(args) != 3 && len(args) != 4 {
+		return errFormat
+	}
+	exptime, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil || !validKey(args[1]) {
+		return errFormat
+	}
+	touched, err := s.expire(ctx, string(args[1]), exptime)
+	if err != nil || noreply(args) {
+		return err
+	}
+	if touched {
+		w.WriteString("TOUCHED\r\n")
+	} else {
+		w.WriteString("NOT_FOUND\r\n")
+	}
+	return nil
+}
+
+// expire sets the expiration time of key. Returns whether the key was found.
+func (s *Server) expire(ctx context.Context, key string, exptime int64) (bool, error) {
+	s.stats.cmdTouch.Add(1)
+	ttl, live := expiry(exptime)
+	var (
+		found bool
+		err   error
+	)
+	if !live {
+		found, err = s.evict(ctx, key)
+	} else if expirer, ok := s.storage.(cache.Expirer); !ok {
+		return false, errors.New("touch is not supported by the storage")
+	} else if err = expirer.Expire(ctx, key, ttl); err == nil {
+		found = true
+	} else if errors.Is(err, errs.ErrNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if found {
+		s.stats.touchHits.Add(1)
+	} else {
+		s.stats.touchMiss.Add(1)
+	}
+	return found, nil
+}
+
+// flushAll removes every key. Delayed flushes are not supported:
+//
+//	flush_all [0] [noreply]
+func (s *Server) flushAll(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	rest := args[1:]
+	if len(rest) > 0 && noreply(args) {
+		rest = rest[:len(rest)-1]
+	}
+	if len(rest) > 1 {
+		return errFormat
+	}
+	if len(rest) == 1 {
+		if delay, err := strconv.ParseInt(string(rest[0]), 10, 64); err != nil || delay != 0 {
+			return clientError("delayed flush is not supported")
+		}
+	}
+	s.stats.cmdFlush.Add(1)
+	if err := s.storage.EvictAll(ctx); err != nil || noreply(args) {
+		return err
+	}
+	w.WriteString("OK\r\n")
+	return nil
+}
+
+// statsCmd reports the general statistics. The item counts, hits, misses, evictions and expirations
+// are those of the cache, so they include the operations made through the other APIs.
+func (s *Server) statsCmd(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	if len(args) > 1 {
+		w.WriteString("ERROR\r\n")
+		return nil
+	}
+	stat := func(name string, value any) {
+		fmt.Fprintf(w, "STAT %s %v\r\n", name, value)
+	}
+	now := time.Now()
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(s.started).Seconds()))
+	stat("time", now.Unix())
+	stat("version", version)
+	stat("pointer_size", strconv.IntSize)
+	stat("curr_connections", s.stats.currConns.Load())
+	stat("total_connections", s.stats.totalConns.Load())
+	stat("cmd_get", s.stats.cmdGet.Load())
+	stat("cmd_set", s.stats.cmdSet.Load())
+	stat("cmd_flush", s.stats.cmdFlush.Load())
+	stat("cmd_touch", s.stats.cmdTouch.Load())
+	if stater, ok := s.storage.(cache.Stater); ok {
+		st := stater.Stats()
+		stat("get_hits", st.Hits)
+		stat("get_misses", st.Misses)
+		stat("curr_items", st.Size)
+		stat("total_items", st.Puts)
+		stat("evictions", st.Evictions)
+		stat("reclaimed", st.Expirations)
+		stat("limit_maxitems", st.Capacity)
+	}
+	stat("delete_misses", s.stats.deleteMiss.Load())
+	stat("delete_hits", s.stats.deleteHits.Load())
+	stat("touch_hits", s.stats.touchHits.Load())
+	stat("touch_misses", s.stats.touchMiss.Load())
+	w.WriteString("END\r\n")
+	return nil
+}
+
+func (s *Server) version(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	w.WriteString("VERSION " + version + "\r\n")
+	return nil
+}
+
+// verbosity is accepted for compatibility and has no effect.
+func (s *Server) verbosity(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	if len(args) < 2 || len(args) > 3 {
+		return errFormat
+	}
+	if !noreply(args) {
+		w.WriteString("OK\r\n")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package memcache serves a cache over the memcached text protocol, both the classic storage and
+// retrieval commands and the meta commands, so that memcached client libraries can use it.
+//
+// Values stored without client flags are kept as strings, so that they read the same over the other APIs.
+// Values with flags are kept as a JSON object holding the data and the flags.
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"lru-cache/internal/cache"
+	"lru-cache/internal/tcpsrv"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Server serves the commands of memcached clients from a cache.
+type Server struct {
+	*tcpsrv.Server
+	storage cache.ILRUCache
+	logger  *slog.Logger
+	started time.Time
+	stats   counters
+}
+
+// counters are the statistics of the commands served, reported by stats along with those of the cache.
+type counters struct {
+	currConns, totalConns  atomic.Int64
+	cmdGet, cmdSet         atomic.Uint64
+	cmdTouch, cmdFlush     atomic.Uint64
+	deleteHits, deleteMiss atomic.Uint64
+	touchHits, touchMiss   atomic.Uint64
+}
+
+// New creates a Server serving the storage.
+func New(storage cache.ILRUCache, logger *slog.Logger) *Server {
+	s := &Server{storage: storage, logger: logger, started: time.Now()}
+	s.Server = tcpsrv.New(s.serveConn)
+	return s
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	s.stats.currConns.Add(1)
+	s.stats.totalConns.Add(1)
+	defer s.stats.currConns.Add(-1)
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				w.WriteString("CLIENT_ERROR line too long\r\n")
+				w.Flush()
+			} else if !errors.Is(err, io.EOF) && !s.Closing() {
+				s.logger.Debug("Memcached connection error", slog.String("remote", conn.RemoteAddr().String()), slog.Any("error", err))
+			}
+			return
+		}
+		stop := s.execute(context.Background(), r, w, line) || s.Closing()
+		// Flush once the pipelined commands already received have all been answered.
+		if r.Buffered() == 0 || stop {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if stop {
+			return
+		}
+	}
+}
+
+// maxExptime is the largest exptime taken as relative to now, larger ones are Unix times.
+const maxExptime = 60 * 60 * 24 * 30
+
+// expiry converts a memcached exptime to a TTL: zero never expires, up to 30 days it is a number
+// of seconds from now and beyond it is an absolute Unix time. Returns false if the exptime has already passed.
+func expiry(exptime int64) (ttl time.Duration, live bool) {
+	switch {
+	case exptime == 0:
+		return 0, true
+	case exptime < 0:
+		return 0, false
+	case exptime <= maxExptime:
+		return time.Duration(exptime) * time.Second, true
+	}
+	ttl = time.Until(time.Unix(exptime, 0))
+	return ttl, ttl > 0
+}
+
+// remaining returns the seconds left before expiresAt, -1 if it is zero.
+func remaining(expiresAt time.Time) int64 {
+	if expiresAt.IsZero() {
+		return -1
+	}
+	return max(int64(time.Until(expiresAt).Round(time.Second)/time.Second), 0)
+}
+
+// encode converts data stored with client flags to a cache value.
+func encode(data []byte, flags uint32) any {
+	if flags == 0 {
+		return string(data)
+	}
+	return map[string]any{"data": string(data), "flags": float64(flags)}
+}
+
+// decode converts a cache value to its data and client flags. Values that were not stored
+// through this package, other than strings, are sent as JSON.
+func decode(value any) (data []byte, flags uint32, err error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), 0, nil
+	case map[string]any:
+		data, okData := v["data"].(string)
+		flags, okFlags := v["flags"].(float64)
+		if len(v) == 2 && okData && okFlags {
+			return []byte(data), uint32(flags), nil
+		}
+	}
+	data, err = json.Marshal(value)
+	return data, 0, err
+}
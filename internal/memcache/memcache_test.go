@@ -0,0 +1,191 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+
+	"lru-cache/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serve starts a Server for c on a local port and returns a connection to it.
+func serve(t *testing.T, c cache.ILRUCache) net.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go s.Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Shutdown(context.Background())
+	})
+	return conn
+}
+
+// exchange sends request and reads as many lines as there are in reply.
+func exchange(t *testing.T, conn net.Conn, r *bufio.Reader, request, reply string) {
+	_, err := conn.Write([]byte(request))
+	assert.NoError(t, err)
+	var b strings.Builder
+	for range strings.Count(reply, "\n") {
+		line, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		b.WriteString(line)
+	}
+	assert.Equal(t, reply, b.String(), request)
+}
+
+// TestClassicCommands checks the replies of the classic text protocol commands.
+func TestClassicCommands(t *testing.T) {
+	ctx := context.Background()
+	c := cache.New(10)
+	c.Put(ctx, "json", []any{1.0, "a"}, 0)
+	conn := serve(t, c)
+	r := bufio.NewReader(conn)
+
+	tests := []struct {
+		request string
+		reply   string
+	}{
+		{"get missing\r\n", "END\r\n"},
+		{"set a 0 0 5\r\nhello\r\n", "STORED\r\n"},
+		{"set b 42 100 3 noreply\r\nbye\r\nget a b missing\r\n", "VALUE a 0 5\r\nhello\r\nVALUE b 42 3\r\nbye\r\nEND\r\n"},
+		{"gets json\r\n", "VALUE json 0 7 1\r\n[1,\"a\"]\r\nEND\r\n"},
+		{"add a 0 0 1\r\nx\r\n", "NOT_STORED\r\n"},
+		{"add c 0 0 1\r\nx\r\n", "STORED\r\n"},
+		{"replace d 0 0 1\r\nx\r\n", "NOT_STORED\r\n"},
+		{"replace c 0 0 1\r\ny\r\n", "STORED\r\n"},
+		{"set c 0 -1 1\r\nz\r\nget c\r\n", "STORED\r\nEND\r\n"},
+		{"set a 0 0 2\r\ntoolong\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+	}
+	for _, test := range tests {
+		exchange(t, conn, r, test.request, test.reply)
+	}
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	conn = serve(t, c)
+	r = bufio.NewReader(conn)
+	tests = []struct {
+		request string
+		reply   string
+	}{
+		{"touch a 0\r\n", "TOUCHED\r\n"},
+		{"touch missing 10\r\n", "NOT_FOUND\r\n"},
+		{"delete a\r\n", "DELETED\r\n"},
+		{"delete a\r\n", "NOT_FOUND\r\n"},
+		{"delete b 0 noreply\r\nversion\r\n", "VERSION " + version + "\r\n"},
+		{"bogus\r\n", "ERROR\r\n"},
+		{"get\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"flush_all 10\r\n", "CLIENT_ERROR delayed flush is not supported\r\n"},
+		{"flush_all\r\nget json\r\n", "OK\r\nEND\r\n"},
+	}
+	for _, test := range tests {
+		exchange(t, conn, r, test.request, test.reply)
+	}
+
+	_, err = conn.Write([]byte("stats\r\n"))
+	assert.NoError(t, err)
+	stats := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		if line == "END\r\n" {
+			break
+		}
+		fields := strings.Fields(line)
+		assert.Len(t, fields, 3)
+		stats[fields[1]] = fields[2]
+	}
+	assert.Equal(t, "0", stats["curr_items"])
+	assert.Equal(t, "2", stats["delete_hits"])
+	assert.Equal(t, "1", stats["touch_misses"])
+	assert.Equal(t, "1", stats["curr_connections"])
+	assert.NotEmpty(t, stats["get_hits"])
+
+	exchange(t, conn, r, "quit\r\n", "")
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// TestMetaCommands checks the replies of the meta commands, including returned flags and quiet mode.
+func TestMetaCommands(t *testing.T) {
+	conn := serve(t, cache.New(10))
+	r := bufio.NewReader(conn)
+
+	tests := []struct {
+		request string
+		reply   string
+	}{
+		{"mg missing v\r\n", "EN\r\n"},
+		{"mg missing v q\r\nmn\r\n", "MN\r\n"},
+		{"ms a 5 F7 T100 c\r\nhello\r\n", "HD c1\r\n"},
+		{"mg a v f t s k Oxyz\r\n", "VA 5 f7 t100 s5 ka Oxyz\r\nhello\r\n"},
+		{"mg a\r\n", "HD\r\n"},
+		{"ms a 1 ME\r\nx\r\n", "NS\r\n"},
+		{"ms b 1 MR\r\nx\r\n", "NS\r\n"},
+		{"ms b 1 ME q\r\nx\r\nmg b v t\r\n", "VA 1 t-1\r\nx\r\n"},
+		{"mg b T0 t\r\n", "HD t-1\r\n"},
+		{"mg a T20 t\r\n", "HD t20\r\n"},
+		{"ms a 1 MA\r\nx\r\n", "CLIENT_ERROR invalid mode for ms STORE\r\n"},
+		{"mg a Z\r\n", "CLIENT_ERROR invalid flag\r\n"},
+		{"md a q\r\nmd a Oq1\r\nmd missing q\r\nmn\r\n", "NF Oq1\r\nMN\r\n"},
+		{"get b\r\n", "VALUE b 0 1\r\nx\r\nEND\r\n"},
+		{"mg b c\r\n", "HD c2\r\n"},
+		{"ms b 1 C1 c\r\ny\r\n", "EX c0\r\n"},
+		{"ms b 1 C2 c\r\ny\r\n", "HD c3\r\n"},
+		{"ms missing 1 C2\r\ny\r\n", "NF\r\n"},
+	}
+	for _, test := range tests {
+		exchange(t, conn, r, test.request, test.reply)
+	}
+}
+
+// TestCAS checks that cas only stores a value if it was not changed since gets read it.
+func TestCAS(t *testing.T) {
+	conn := serve(t, cache.New(10))
+	r := bufio.NewReader(conn)
+
+	tests := []struct {
+		request string
+		reply   string
+	}{
+		{"cas a 0 0 1 1\r\nx\r\n", "NOT_FOUND\r\n"},
+		{"set a 0 0 1\r\nx\r\ngets a\r\n", "STORED\r\nVALUE a 0 1 1\r\nx\r\nEND\r\n"},
+		{"cas a 0 0 1 1\r\ny\r\n", "STORED\r\n"},
+		{"cas a 0 0 1 1\r\nz\r\n", "EXISTS\r\n"},
+		{"gets a\r\n", "VALUE a 0 1 2\r\ny\r\nEND\r\n"},
+		{"cas a 0 -1 1 2 noreply\r\nz\r\nget a\r\n", "END\r\n"},
+		{"cas a 0 0 1\r\nz\r\n", "CLIENT_ERROR bad command line format\r\n"},
+	}
+	for _, test := range tests {
+		exchange(t, conn, r, test.request, test.reply)
+	}
+
+	conn = serve(t, cache.NewMockCache())
+	r = bufio.NewReader(conn)
+	exchange(t, conn, r, "cas a 0 0 1 1\r\nz\r\n", "SERVER_ERROR "+errNoCAS.Error()+"\r\n")
+}
+
+// TestExpiry checks the conversion of exptimes to TTLs.
+func TestExpiry(t *testing.T) {
+	ttl, live := expiry(0)
+	assert.True(t, live)
+	assert.Zero(t, ttl)
+
+	ttl, live = expiry(maxExptime)
+	assert.True(t, live)
+	assert.Equal(t, int64(maxExptime), int64(ttl.Seconds()))
+
+	_, live = expiry(-1)
+	assert.False(t, live)
+	_, live = expiry(maxExptime + 1)
+	assert.False(t, live)
+}
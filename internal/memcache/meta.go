@@ -0,0 +1,249 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"lru-cache/internal/cache"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The meta commands take flags made of a letter, some followed by a token. The flags asking for
+// something to be returned are answered in the order they were given, see returned.
+
+// metaGet retrieves a key, along with the information asked for by its flags:
+//
+//	mg <key> <flags>*
+//
+// Supported flags: c (return CAS), f (return client flags), k (return key), O (opaque echoed back),
+// q (no EN on miss), s (return size), t (return remaining TTL), v (return value), T (update TTL).
+func (s *Server) metaGet(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	if len(args) < 2 || !validKey(args[1]) {
+		return errFormat
+	}
+	key, flags := string(args[1]), args[2:]
+	var (
+		quiet, value bool
+		exptime      *int64
+	)
+	for _, flag := range flags {
+		switch flag[0] {
+		case 'q':
+			quiet = true
+		case 'v':
+			value = true
+		case 'T':
+			n, err := strconv.ParseInt(string(flag[1:]), 10, 64)
+			if err != nil {
+				return errFormat
+			}
+			exptime = &n
+		case 'c', 'f', 'k', 'O', 's', 't':
+		default:
+			return errFlag
+		}
+	}
+
+	if exptime != nil {
+		if _, err := s.expire(ctx, key, *exptime); err != nil {
+			return err
+		}
+	}
+	s.stats.cmdGet.Add(1)
+	item, err := cache.GetItem(ctx, s.storage, key)
+	if errors.Is(err, errs.ErrNotFound) {
+		if !quiet {
+			w.WriteString("EN\r\n")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, clientFlags, err := decode(item.Value)
+	if err != nil {
+		return err
+	}
+	ret := returned(flags, key, len(data), clientFlags, item.ExpiresAt, item.Version)
+	if !value {
+		w.WriteString("HD" + ret + "\r\n")
+		return nil
+	}
+	fmt.Fprintf(w, "VA %d%s\r\n", len(data), ret)
+	w.Write(data)
+	w.WriteString("\r\n")
+	return nil
+}
+
+// metaSet stores a key:
+//
+//	ms <key> <datalen> <flags>*\r\n<data>\r\n
+//
+// Supported flags: c (return CAS), k (return key), O (opaque echoed back), q (no HD on success),
+// F (client flags), T (TTL, an exptime), M (mode: E add, R replace, S set, the default),
+// C (compare CAS: store only if the entry still has this CAS, replying EX if it changed and NF if it is missing,
+// in which case the mode is ignored).
+func (s *Server) metaSet(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	if len(args) < 3 {
+		return errFormat
+	}
+	n, err := strconv.Atoi(string(args[2]))
+	if err != nil || n < 0 {
+		return errFormat
+	}
+	if n > maxValue {
+		if _, err := r.Discard(n + 2); err != nil {
+			return connError{err}
+		}
+		w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return nil
+	}
+	data, err := readData(r, n)
+	if err != nil {
+		return err
+	}
+	if !validKey(args[1]) {
+		return errFormat
+	}
+	key, flags := string(args[1]), args[3:]
+	var (
+		quiet       bool
+		clientFlags uint64
+		exptime     int64
+		mode        lru.Mode
+		compare     *uint64
+	)
+	for _, flag := range flags {
+		var err error
+		switch flag[0] {
+		case 'q':
+			quiet = true
+		case 'F':
+			clientFlags, err = strconv.ParseUint(string(flag[1:]), 10, 32)
+		case 'T':
+			exptime, err = strconv.ParseInt(string(flag[1:]), 10, 64)
+		case 'M':
+			switch strings.ToUpper(string(flag[1:])) {
+			case "E":
+				mode = lru.IfAbsent
+			case "R":
+				mode = lru.IfPresent
+			case "S":
+				mode = 0
+			default:
+				return clientError("invalid mode for ms STORE")
+			}
+		case 'C':
+			var version uint64
+			version, err = strconv.ParseUint(string(flag[1:]), 10, 64)
+			compare = &version
+		case 'c', 'k', 'O':
+		default:
+			return errFlag
+		}
+		if err != nil {
+			return errFormat
+		}
+	}
+
+	s.stats.cmdSet.Add(1)
+	value := encode(data, uint32(clientFlags))
+	stored, found := false, true
+	if compare != nil {
+		stored, found, err = s.swap(ctx, key, value, exptime, *compare)
+	} else {
+		stored, err = s.store(ctx, key, value, exptime, mode)
+	}
+	if err != nil {
+		return err
+	}
+	var version uint64
+	if versioner, ok := s.storage.(cache.Versioner); ok && stored {
+		version, _ = versioner.Version(ctx, key)
+	}
+	ret := returned(flags, key, 0, 0, time.Time{}, version)
+	switch {
+	case !found:
+		w.WriteString("NF" + ret + "\r\n")
+	case !stored && compare != nil:
+		w.WriteString("EX" + ret + "\r\n")
+	case !stored:
+		w.WriteString("NS" + ret + "\r\n")
+	case !quiet:
+		w.WriteString("HD" + ret + "\r\n")
+	}
+	return nil
+}
+
+// metaDelete removes a key:
+//
+//	md <key> <flags>*
+//
+// Supported flags: k (return key), O (opaque echoed back), q (no reply).
+func (s *Server) metaDelete(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	if len(args) < 2 || !validKey(args[1]) {
+		return errFormat
+	}
+	key, flags := string(args[1]), args[2:]
+	quiet := false
+	for _, flag := range flags {
+		switch flag[0] {
+		case 'q':
+			quiet = true
+		case 'k', 'O':
+		default:
+			return errFlag
+		}
+	}
+
+	deleted, err := s.evict(ctx, key)
+	if err != nil {
+		return err
+	}
+	ret := returned(flags, key, 0, 0, time.Time{}, 0)
+	if deleted {
+		s.stats.deleteHits.Add(1)
+		if !quiet {
+			w.WriteString("HD" + ret + "\r\n")
+		}
+	} else {
+		s.stats.deleteMiss.Add(1)
+		if !quiet {
+			w.WriteString("NF" + ret + "\r\n")
+		}
+	}
+	return nil
+}
+
+// metaNoop replies MN, which clients send after quiet commands to know when they have all been processed.
+func (s *Server) metaNoop(ctx context.Context, r *bufio.Reader, w *bufio.Writer, args [][]byte) error {
+	w.WriteString("MN\r\n")
+	return nil
+}
+
+// returned formats the flags of a reply asked for by the flags of a meta command.
+func returned(flags [][]byte, key string, size int, clientFlags uint32, expiresAt time.Time, cas uint64) string {
+	var b strings.Builder
+	for _, flag := range flags {
+		switch flag[0] {
+		case 'c':
+			fmt.Fprintf(&b, " c%d", cas)
+		case 'f':
+			fmt.Fprintf(&b, " f%d", clientFlags)
+		case 'k':
+			b.WriteString(" k" + key)
+		case 'O':
+			fmt.Fprintf(&b, " O%s", flag[1:])
+		case 's':
+			fmt.Fprintf(&b, " s%d", size)
+		case 't':
+			fmt.Fprintf(&b, " t%d", remaining(expiresAt))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	// maxLine is the longest command line accepted.
+	maxLine = 8 << 10
+	// maxKey is the longest key accepted, as in memcached.
+	maxKey = 250
+	// maxValue is the largest value accepted, memcached's default item size limit.
+	maxValue = 1 << 20
+)
+
+// errLineTooLong is returned for command lines longer than maxLine, after which the connection is closed.
+var errLineTooLong = errors.New("line too long")
+
+// readLine reads a command line terminated by CRLF or LF and splits it into its tokens.
+func readLine(r *bufio.Reader) ([][]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxLine {
+			return nil, errLineTooLong
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+	return bytes.Fields(line), nil
+}
+
+// connError is an error reading from the connection, after which it is closed without a reply.
+type connError struct{ error }
+
+func (e connError) Unwrap() error { return e.error }
+
+// readData reads a data block of n bytes followed by CRLF.
+func readData(r *bufio.Reader, n int) ([]byte, error) {
+	data := make([]byte, n+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, connError{err}
+	}
+	if data[n] != '\r' || data[n+1] != '\n' {
+		return nil, errBadChunk
+	}
+	return data[:n], nil
+}
+
+// validKey tells whether key is short enough and free of control characters.
+func validKey(key []byte) bool {
+	if len(key) == 0 || len(key) > maxKey {
+		return false
+	}
+	for _, c := range key {
+		if c <= ' ' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
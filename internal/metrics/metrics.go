@@ -0,0 +1,319 @@
+// Package metrics provides counters, histograms and gauges exposed in the Prometheus text exposition format.
+// It implements only what the cache server needs, without depending on the Prometheus client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histogram buckets used by default.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Registry holds a set of metrics and renders them for scraping.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// Write renders every metric of the registry in the Prometheus text exposition format, in registration order.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the metrics of the registry to a Prometheus scraper.
+func (r *Registry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(rw); err != nil {
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increments the counter by v, which must not be negative.
+func (c *Counter) Add(v float64) {
+	addFloat(&c.bits, v)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Histogram counts observations in buckets of configurable upper bounds.
+type Histogram struct {
+	upper  []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Uint64
+}
+
+// Observe records a single observation.
+func (h *Histogram) Observe(v float64) {
+	if i, _ := slices.BinarySearch(h.upper, v); i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	addFloat(&h.sum, v)
+}
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// vec is a family of series of the same metric distinguished by label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	create func() *T
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+}
+
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = v.create()
+		v.series[key] = s
+		v.values[key] = slices.Clone(values)
+	}
+	return s
+}
+
+// each calls fn for every series, sorted by label values so the output is stable.
+func (v *vec[T]) each(fn func(values []string, s *T) error) error {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	series := make([]*T, len(keys))
+	values := make([][]string, len(keys))
+	for i, key := range keys {
+		series[i] = v.series[key]
+		values[i] = v.values[key]
+	}
+	v.mu.Unlock()
+
+	for i := range keys {
+		if err := fn(values[i], series[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newVec[T any](name, help, kind string, labels []string, create func() *T) *vec[T] {
+	return &vec[T]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		create: create,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+	}
+}
+
+// CounterVec is a family of counters partitioned by labels.
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// NewCounterVec registers a family of counters with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newVec(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the counter of the series with the given label values, creating it if needed.
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.with(values)
+}
+
+func (v *CounterVec) write(w io.Writer) error {
+	if err := writeHeader(w, v.name, v.help, v.kind); err != nil {
+		return err
+	}
+	return v.each(func(values []string, c *Counter) error {
+		return writeSample(w, v.name, formatLabels(v.labels, values), c.Value())
+	})
+}
+
+// HistogramVec is a family of histograms partitioned by labels.
+type HistogramVec struct {
+	*vec[Histogram]
+	upper []float64
+}
+
+// NewHistogramVec registers a family of histograms with the given sorted bucket upper bounds and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	upper := slices.Clone(buckets)
+	create := func() *Histogram {
+		return &Histogram{upper: upper, counts: make([]atomic.Uint64, len(upper))}
+	}
+	v := &HistogramVec{vec: newVec(name, help, "histogram", labels, create), upper: upper}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the histogram of the series with the given label values, creating it if needed.
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.with(values)
+}
+
+func (v *HistogramVec) write(w io.Writer) error {
+	if err := writeHeader(w, v.name, v.help, v.kind); err != nil {
+		return err
+	}
+	le := append(slices.Clone(v.labels), "le")
+	return v.each(func(values []string, h *Histogram) error {
+		labels := formatLabels(v.labels, values)
+		values = slices.Clip(values)
+		cumulative := uint64(0)
+		for i, upper := range v.upper {
+			cumulative += h.counts[i].Load()
+			bucket := formatLabels(le, append(values, formatFloat(upper)))
+			if err := writeSample(w, v.name+"_bucket", bucket, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		count := h.count.Load()
+		if err := writeSample(w, v.name+"_bucket", formatLabels(le, append(values, "+Inf")), float64(count)); err != nil {
+			return err
+		}
+		if err := writeSample(w, v.name+"_sum", labels, math.Float64frombits(h.sum.Load())); err != nil {
+			return err
+		}
+		return writeSample(w, v.name+"_count", labels, float64(count))
+	})
+}
+
+// valueFunc is a metric without labels whose value is read from a function at scrape time.
+type valueFunc struct {
+	name string
+	help string
+	kind string
+	fn   func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is computed by fn on every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is computed by fn on every scrape.
+// fn must never return a smaller value than it did before.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{name: name, help: help, kind: "counter", fn: fn})
+}
+
+func (f *valueFunc) write(w io.Writer) error {
+	if err := writeHeader(w, f.name, f.help, f.kind); err != nil {
+		return err
+	}
+	return writeSample(w, f.name, "", f.fn())
+}
+
+func writeHeader(w io.Writer, name, help, kind string) error {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	return err
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) error {
+	_, err := fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders label pairs as {name="value",...}, or nothing if there are no labels.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
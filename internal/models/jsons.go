@@ -10,6 +10,8 @@ type PostRequest struct {
 	Key        string      `json:"key"`
 	Value      interface{} `json:"value"`
 	TTLSeconds int         `json:"ttl_seconds"`
+	Mode       string      `json:"mode,omitempty"`
+	IfVersion  uint64      `json:"if_version,omitempty"`
 }
 
 func (v *PostRequest) FromJSON(r io.Reader) error {
@@ -27,6 +29,9 @@ type GetResponse struct {
 	Value         interface{} `json:"value,omitempty"`
 	TimeExpiresAt time.Time   `json:"-"`
 	ExpiresAt     int         `json:"expires_at,omitempty"`
+	Stale         bool        `json:"stale,omitempty"`
+	Refreshing    bool        `json:"refreshing,omitempty"`
+	Version       uint64      `json:"version,omitempty"`
 }
 
 func (v *GetResponse) FromJSON(r io.Reader) error {
@@ -37,16 +42,161 @@ func (v *GetResponse) FromJSON(r io.Reader) error {
 
 func (v *GetResponse) ToJSON(w io.Writer) error {
 	e := json.NewEncoder(w)
-	v.ExpiresAt = int(v.TimeExpiresAt.Unix())
+	if !v.TimeExpiresAt.IsZero() {
+		v.ExpiresAt = int(v.TimeExpiresAt.Unix())
+	}
+	return e.Encode(v)
+}
+
+type IncrRequest struct {
+	Delta      json.Number `json:"delta,omitempty"`
+	TTLSeconds int         `json:"ttl_seconds"`
+}
+
+func (v *IncrRequest) FromJSON(r io.Reader) error {
+	d := json.NewDecoder(r)
+	return d.Decode(v)
+}
+
+type IncrResponse struct {
+	Key           string      `json:"key"`
+	Value         interface{} `json:"value"`
+	TimeExpiresAt time.Time   `json:"-"`
+	ExpiresAt     int         `json:"expires_at,omitempty"`
+}
+
+func (v *IncrResponse) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	if !v.TimeExpiresAt.IsZero() {
+		v.ExpiresAt = int(v.TimeExpiresAt.Unix())
+	}
 	return e.Encode(v)
 }
 
 type GetAllResponse struct {
 	Keys   []string      `json:"keys"`
 	Values []interface{} `json:"values"`
+	Weight int64         `json:"weight,omitempty"`
 }
 
 func (v *GetAllResponse) ToJSON(w io.Writer) error {
 	e := json.NewEncoder(w)
 	return e.Encode(v)
 }
+
+type StatsResponse struct {
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRatio    float64 `json:"hit_ratio"`
+	Puts        uint64  `json:"puts"`
+	Evictions   uint64  `json:"evictions"`
+	Expirations uint64  `json:"expirations"`
+	Removals    uint64  `json:"removals"`
+	Admitted    uint64  `json:"admitted"`
+	Rejected    uint64  `json:"rejected"`
+	Size        int     `json:"size"`
+	Capacity    int     `json:"capacity"`
+	Weight      int64   `json:"weight"`
+	MaxWeight   int64   `json:"max_weight"`
+}
+
+func (v *StatsResponse) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	return e.Encode(v)
+}
+
+type BatchRequest struct {
+	Keys []string `json:"keys"`
+}
+
+func (v *BatchRequest) FromJSON(r io.Reader) error {
+	d := json.NewDecoder(r)
+	return d.Decode(v)
+}
+
+type MSetRequest struct {
+	Entries []PostRequest `json:"entries"`
+}
+
+func (v *MSetRequest) FromJSON(r io.Reader) error {
+	d := json.NewDecoder(r)
+	return d.Decode(v)
+}
+
+type BatchResult struct {
+	Key           string      `json:"key"`
+	Found         bool        `json:"found"`
+	Value         interface{} `json:"value,omitempty"`
+	TimeExpiresAt time.Time   `json:"-"`
+	ExpiresAt     int         `json:"expires_at,omitempty"`
+	Stale         bool        `json:"stale,omitempty"`
+	Refreshing    bool        `json:"refreshing,omitempty"`
+	Version       uint64      `json:"version,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+func (v *BatchResponse) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	for i := range v.Results {
+		if !v.Results[i].TimeExpiresAt.IsZero() {
+			v.Results[i].ExpiresAt = int(v.Results[i].TimeExpiresAt.Unix())
+		}
+	}
+	return e.Encode(v)
+}
+
+type MSetResult struct {
+	Key    string `json:"key"`
+	Stored bool   `json:"stored"`
+}
+
+type MSetResponse struct {
+	Results []MSetResult `json:"results"`
+}
+
+func (v *MSetResponse) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	return e.Encode(v)
+}
+
+type ScanEntry struct {
+	Key           string      `json:"key"`
+	Value         interface{} `json:"value"`
+	TimeExpiresAt time.Time   `json:"-"`
+	ExpiresAt     int         `json:"expires_at,omitempty"`
+}
+
+type ScanResponse struct {
+	Entries    []ScanEntry `json:"entries"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+func (v *ScanResponse) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	for i := range v.Entries {
+		if !v.Entries[i].TimeExpiresAt.IsZero() {
+			v.Entries[i].ExpiresAt = int(v.Entries[i].TimeExpiresAt.Unix())
+		}
+	}
+	return e.Encode(v)
+}
+
+type ExportEntry struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt int         `json:"expires_at,omitempty"`
+	Rank      int         `json:"rank"`
+}
+
+type ImportResponse struct {
+	Imported int `json:"imported"`
+	Expired  int `json:"expired"`
+}
+
+func (v *ImportResponse) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	return e.Encode(v)
+}
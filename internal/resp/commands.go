@@ -0,0 +1,454 @@
+package resp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"lru-cache/internal/cache"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/glob"
+	"lru-cache/pkg/lru"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// command is a command handler along with its arity, counted Redis-style including the command name:
+// a positive arity is the exact number of arguments, a negative one the minimum.
+type command struct {
+	arity int
+	run   func(s *Server, ctx context.Context, w *writer, args [][]byte) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":     {-1, (*Server).ping},
+		"ECHO":     {2, (*Server).echo},
+		"HELLO":    {-1, (*Server).hello},
+		"SELECT":   {2, (*Server).selectDB},
+		"CLIENT":   {-2, (*Server).client},
+		"COMMAND":  {-1, (*Server).command},
+		"GET":      {2, (*Server).get},
+		"SET":      {-3, (*Server).set},
+		"DEL":      {-2, (*Server).del},
+		"EXISTS":   {-2, (*Server).exists},
+		"TTL":      {2, (*Server).ttl},
+		"PTTL":     {2, (*Server).pttl},
+		"EXPIRE":   {3, (*Server).expire},
+		"KEYS":     {2, (*Server).keys},
+		"FLUSHALL": {-1, (*Server).flushAll},
+		"DBSIZE":   {1, (*Server).dbSize},
+		"INFO":     {-1, (*Server).info},
+	}
+}
+
+// replyError is an error caused by the command itself, replied as it is. Other errors come from the storage,
+// they are logged and replied with the ERR prefix.
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+const (
+	errSyntax replyError = "ERR syntax error"
+	errNotInt replyError = "ERR value is not an integer or out of range"
+)
+
+// execute runs a command and writes its reply. Returns true if the client asked to close the connection.
+func (s *Server) execute(ctx context.Context, w *writer, args [][]byte) (quit bool) {
+	name := strings.ToUpper(string(args[0]))
+	if name == "QUIT" {
+		w.simple("OK")
+		return true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		w.error(fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", args[0], quoteArgs(args[1:])))
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+	if err := cmd.run(s, ctx, w, args); err != nil {
+		var reply replyError
+		if !errors.As(err, &reply) {
+			s.logger.Warn("Something went wrong in a RESP command", slog.String("command", name), slog.Any("error", err))
+			reply = replyError("ERR " + err.Error())
+		}
+		w.error(string(reply))
+	}
+	return false
+}
+
+func quoteArgs(args [][]byte) string {
+	var b strings.Builder
+	for _, arg := range args {
+		fmt.Fprintf(&b, "'%s' ", arg)
+	}
+	return b.String()
+}
+
+func (s *Server) ping(ctx context.Context, w *writer, args [][]byte) error {
+	switch len(args) {
+	case 1:
+		w.simple("PONG")
+	case 2:
+		w.bulk(args[1])
+	default:
+		return replyError("ERR wrong number of arguments for 'ping' command")
+	}
+	return nil
+}
+
+func (s *Server) echo(ctx context.Context, w *writer, args [][]byte) error {
+	w.bulk(args[1])
+	return nil
+}
+
+// hello switches the protocol version and describes the server. AUTH and SETNAME are accepted and ignored.
+func (s *Server) hello(ctx context.Context, w *writer, args [][]byte) error {
+	if len(args) > 1 {
+		proto, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return replyError("ERR Protocol version is not an integer or out of range")
+		}
+		if proto != 2 && proto != 3 {
+			return replyError("NOPROTO unsupported protocol version")
+		}
+		w.proto = proto
+	}
+	w.mapHeader(7)
+	w.bulkString("server")
+	w.bulkString("redis")
+	w.bulkString("version")
+	w.bulkString(redisVersion)
+	w.bulkString("proto")
+	w.integer(int64(w.proto))
+	w.bulkString("id")
+	w.integer(1)
+	w.bulkString("mode")
+	w.bulkString("standalone")
+	w.bulkString("role")
+	w.bulkString("master")
+	w.bulkString("modules")
+	w.array(0)
+	return nil
+}
+
+func (s *Server) selectDB(ctx context.Context, w *writer, args [][]byte) error {
+	if string(args[1]) != "0" {
+		return replyError("ERR DB index is out of range")
+	}
+	w.simple("OK")
+	return nil
+}
+
+// client accepts the CLIENT subcommands clients send on connect, such as SETNAME and SETINFO, without effect.
+func (s *Server) client(ctx context.Context, w *writer, args [][]byte) error {
+	w.simple("OK")
+	return nil
+}
+
+// command replies with no command documentation, which clients such as redis-cli handle gracefully.
+func (s *Server) command(ctx context.Context, w *writer, args [][]byte) error {
+	w.array(0)
+	return nil
+}
+
+func (s *Server) get(ctx context.Context, w *writer, args [][]byte) error {
+	value, _, err := s.storage.Get(ctx, string(args[1]))
+	if errors.Is(err, errs.ErrNotFound) {
+		w.null()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b, err := encode(value)
+	if err != nil {
+		return err
+	}
+	w.bulk(b)
+	return nil
+}
+
+// set stores a string value. Unlike the HTTP API, a key set without EX or PX never expires, as in Redis.
+func (s *Server) set(ctx context.Context, w *writer, args [][]byte) error {
+	key, value := string(args[1]), string(args[2])
+	var (
+		ttl  time.Duration
+		mode lru.Mode
+	)
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "NX", "XX":
+			if mode != 0 {
+				return errSyntax
+			}
+			mode = lru.IfAbsent
+			if opt == "XX" {
+				mode = lru.IfPresent
+			}
+		case "EX", "PX":
+			if ttl != 0 || i+1 == len(args) {
+				return errSyntax
+			}
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return errNotInt
+			}
+			unit := time.Millisecond
+			if opt == "EX" {
+				unit = time.Second
+			}
+			if n <= 0 || n > math.MaxInt64/int64(unit) {
+				return replyError("ERR invalid expire time in 'set' command")
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			return errSyntax
+		}
+	}
+
+	if mode == 0 {
+		if err := s.storage.Put(ctx, key, value, ttl); err != nil {
+			return err
+		}
+		w.simple("OK")
+		return nil
+	}
+	stored, err := cache.PutIf(ctx, s.storage, key, value, ttl, mode)
+	if err != nil {
+		return err
+	}
+	if stored {
+		w.simple("OK")
+	} else {
+		w.null()
+	}
+	return nil
+}
+
+func (s *Server) del(ctx context.Context, w *writer, args [][]byte) error {
+	n := int64(0)
+	for _, key := range args[1:] {
+		_, err := s.storage.Evict(ctx, string(key))
+		switch {
+		case err == nil:
+			n++
+		case !errors.Is(err, errs.ErrNotFound) && !errors.Is(err, errs.ErrCacheIsEmpty):
+			return err
+		}
+	}
+	w.integer(n)
+	return nil
+}
+
+func (s *Server) exists(ctx context.Context, w *writer, args [][]byte) error {
+	n := int64(0)
+	for _, key := range args[1:] {
+		_, _, err := cache.Peek(ctx, s.storage, string(key))
+		switch {
+		case err == nil:
+			n++
+		case !errors.Is(err, errs.ErrNotFound):
+			return err
+		}
+	}
+	w.integer(n)
+	return nil
+}
+
+func (s *Server) ttl(ctx context.Context, w *writer, args [][]byte) error {
+	return s.replyTTL(ctx, w, string(args[1]), time.Second)
+}
+
+func (s *Server) pttl(ctx context.Context, w *writer, args [][]byte) error {
+	return s.replyTTL(ctx, w, string(args[1]), time.Millisecond)
+}
+
+// replyTTL replies with the remaining TTL of key in the given unit, rounded to the nearest one,
+// -1 if the key never expires and -2 if it does not exist.
+func (s *Server) replyTTL(ctx context.Context, w *writer, key string, unit time.Duration) error {
+	_, expiresAt, err := cache.Peek(ctx, s.storage, key)
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		w.integer(-2)
+	case err != nil:
+		return err
+	case expiresAt.IsZero():
+		w.integer(-1)
+	default:
+		w.integer(int64((time.Until(expiresAt) + unit/2) / unit))
+	}
+	return nil
+}
+
+// expire sets the TTL of a key in seconds. A non-positive TTL deletes the key, as in Redis,
+// and one too large for a time.Duration is rejected.
+func (s *Server) expire(ctx context.Context, w *writer, args [][]byte) error {
+	key := string(args[1])
+	seconds, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+	if seconds <= 0 {
+		return s.del(ctx, w, args[:2])
+	}
+	if seconds > math.MaxInt64/int64(time.Second) {
+		return replyError("ERR invalid expire time in 'expire' command")
+	}
+	expirer, ok := s.storage.(cache.Expirer)
+	if !ok {
+		return replyError("ERR EXPIRE is not supported by the storage")
+	}
+	err = expirer.Expire(ctx, key, time.Duration(seconds)*time.Second)
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		w.integer(0)
+	case err != nil:
+		return err
+	default:
+		w.integer(1)
+	}
+	return nil
+}
+
+// keys replies with the keys matching a glob pattern, leaving out the expired ones not removed yet.
+func (s *Server) keys(ctx context.Context, w *writer, args [][]byte) error {
+	pattern := string(args[1])
+	keys, err := s.scanKeys(ctx, func(key string) bool { return glob.Match(pattern, key) })
+	if err != nil {
+		return err
+	}
+	w.array(len(keys))
+	for _, key := range keys {
+		w.bulkString(key)
+	}
+	return nil
+}
+
+// scanPage is the number of entries KEYS reads from the cache at a time.
+const scanPage = 1000
+
+// scanKeys returns the live keys that satisfy match, least recently used first.
+// If the cache can be scanned, it is read a page at a time; otherwise it is read at once.
+func (s *Server) scanKeys(ctx context.Context, match func(key string) bool) ([]string, error) {
+	scanner, ok := s.storage.(cache.Scanner)
+	if !ok {
+		keys, _, err := s.storage.GetAll(ctx)
+		if err != nil && !errors.Is(err, errs.ErrCacheIsEmpty) {
+			return nil, err
+		}
+		matched := keys[:0]
+		for _, key := range keys {
+			if match(key) {
+				matched = append(matched, key)
+			}
+		}
+		return matched, nil
+	}
+	var (
+		keys   []string
+		cursor string
+	)
+	for {
+		entries, next, err := scanner.Scan(ctx, cursor, scanPage, lru.LeastRecent, match)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+		if next == "" {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
+// flushAll removes every key. The ASYNC and SYNC modifiers are accepted, the removal is always synchronous.
+func (s *Server) flushAll(ctx context.Context, w *writer, args [][]byte) error {
+	if len(args) > 2 {
+		return errSyntax
+	}
+	if len(args) == 2 {
+		if mode := strings.ToUpper(string(args[1])); mode != "ASYNC" && mode != "SYNC" {
+			return errSyntax
+		}
+	}
+	if err := s.storage.EvictAll(ctx); err != nil {
+		return err
+	}
+	w.simple("OK")
+	return nil
+}
+
+func (s *Server) dbSize(ctx context.Context, w *writer, args [][]byte) error {
+	n, err := s.size(ctx)
+	if err != nil {
+		return err
+	}
+	w.integer(int64(n))
+	return nil
+}
+
+// size returns the number of keys in the storage, including expired ones not removed yet if it reports statistics,
+// as Redis does. Otherwise the live keys are counted.
+func (s *Server) size(ctx context.Context) (int, error) {
+	if stater, ok := s.storage.(cache.Stater); ok {
+		return stater.Stats().Size, nil
+	}
+	keys, err := s.scanKeys(ctx, func(string) bool { return true })
+	return len(keys), err
+}
+
+// redisVersion is the Redis version reported to clients, some of which check it before using a command.
+const redisVersion = "7.2.0"
+
+// info describes the server in the format of Redis' INFO, with the server, stats and keyspace sections.
+func (s *Server) info(ctx context.Context, w *writer, args [][]byte) error {
+	n, err := s.size(ctx)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("# Server\r\n")
+	fmt.Fprintf(&b, "redis_version:%s\r\n", redisVersion)
+	b.WriteString("redis_mode:standalone\r\n")
+	fmt.Fprintf(&b, "os:%s %s\r\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "uptime_in_seconds:%d\r\n", int64(time.Since(s.started).Seconds()))
+	b.WriteString("\r\n# Stats\r\n")
+	if stater, ok := s.storage.(cache.Stater); ok {
+		st := stater.Stats()
+		fmt.Fprintf(&b, "keyspace_hits:%d\r\n", st.Hits)
+		fmt.Fprintf(&b, "keyspace_misses:%d\r\n", st.Misses)
+		fmt.Fprintf(&b, "evicted_keys:%d\r\n", st.Evictions)
+		fmt.Fprintf(&b, "expired_keys:%d\r\n", st.Expirations)
+	}
+	b.WriteString("\r\n# Keyspace\r\n")
+	if n > 0 {
+		fmt.Fprintf(&b, "db0:keys=%d,expires=0,avg_ttl=0\r\n", n)
+	}
+	w.bulkString(b.String())
+	return nil
+}
+
+// encode converts a cached value to the bytes of a bulk string: strings are sent as they are,
+// other values, stored through the HTTP API, as JSON.
+func encode(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,155 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// maxBulk is the largest bulk string accepted in a command, as in Redis.
+	maxBulk = 512 << 20
+	// maxArgs is the largest number of arguments accepted in a command.
+	maxArgs = 1 << 20
+	// maxInline is the longest inline command accepted.
+	maxInline = 64 << 10
+	// preallocated bounds the memory allocated for a command from the lengths it declares,
+	// in bytes for a bulk string and in arguments for an array, before the data actually arrives.
+	preallocated = 64 << 10
+)
+
+// errProtocol is returned for malformed input, after which the connection is closed as in Redis.
+var errProtocol = errors.New("Protocol error")
+
+// readCommand reads a command sent either as an array of bulk strings or inline, as typed in telnet.
+// Returns no arguments for an empty inline command.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r, maxInline)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	args := make([][]byte, 0, min(max(n, 0), preallocated))
+	for range n {
+		line, err := readLine(r, maxInline)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got '%s'", errProtocol, line)
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulk {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		// The buffer grows as the data arrives, so that a client can't make the server allocate
+		// up to maxBulk for every bulk string it announces without sending it.
+		var buf bytes.Buffer
+		buf.Grow(min(size+2, preallocated))
+		if _, err := io.CopyN(&buf, r, int64(size+2)); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		arg := buf.Bytes()
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// readLine reads a line terminated by CRLF or LF, without the terminator.
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return nil, fmt.Errorf("%w: too big request", errProtocol)
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// writer encodes replies in RESP2, or RESP3 once the client has switched with HELLO 3.
+type writer struct {
+	*bufio.Writer
+	proto int
+}
+
+func (w *writer) simple(s string) {
+	w.WriteByte('+')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) error(msg string) {
+	w.WriteByte('-')
+	w.WriteString(msg)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+func (w *writer) bulk(b []byte) {
+	w.WriteByte('$')
+	w.WriteString(strconv.Itoa(len(b)))
+	w.WriteString("\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) bulkString(s string) {
+	w.bulk([]byte(s))
+}
+
+func (w *writer) null() {
+	if w.proto == 3 {
+		w.WriteString("_\r\n")
+	} else {
+		w.WriteString("$-1\r\n")
+	}
+}
+
+func (w *writer) array(n int) {
+	w.WriteByte('*')
+	w.WriteString(strconv.Itoa(n))
+	w.WriteString("\r\n")
+}
+
+// mapHeader starts a map of n pairs, sent as an array of 2n elements in RESP2.
+func (w *writer) mapHeader(n int) {
+	if w.proto == 3 {
+		w.WriteByte('%')
+		w.WriteString(strconv.Itoa(n))
+		w.WriteString("\r\n")
+	} else {
+		w.array(2 * n)
+	}
+}
@@ -0,0 +1,60 @@
+// Package resp serves a cache over the Redis serialization protocol (RESP2 and RESP3),
+// so that Redis clients and tools can use it for the commands it supports.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"lru-cache/internal/cache"
+	"lru-cache/internal/tcpsrv"
+	"net"
+	"time"
+)
+
+// Server serves the commands of Redis clients from a cache.
+type Server struct {
+	*tcpsrv.Server
+	storage cache.ILRUCache
+	logger  *slog.Logger
+	started time.Time
+}
+
+// New creates a Server serving the storage.
+func New(storage cache.ILRUCache, logger *slog.Logger) *Server {
+	s := &Server{storage: storage, logger: logger, started: time.Now()}
+	s.Server = tcpsrv.New(s.serveConn)
+	return s
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := &writer{Writer: bufio.NewWriter(conn), proto: 2}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR " + err.Error())
+				w.Flush()
+			} else if !errors.Is(err, io.EOF) && !s.Closing() {
+				s.logger.Debug("RESP connection error", slog.String("remote", conn.RemoteAddr().String()), slog.Any("error", err))
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		stop := s.execute(context.Background(), w, args) || s.Closing()
+		// Flush once the pipelined commands already received have all been answered.
+		if r.Buffered() == 0 || stop {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if stop {
+			return
+		}
+	}
+}
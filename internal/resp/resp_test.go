@@ -0,0 +1,188 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"lru-cache/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serve starts a Server for c on a local port and returns a connection to it.
+func serve(t *testing.T, c cache.ILRUCache) (*Server, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go s.Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Shutdown(context.Background())
+	})
+	return s, conn
+}
+
+// send writes the commands as arrays of bulk strings in one go.
+func send(t *testing.T, conn net.Conn, commands ...string) {
+	var b strings.Builder
+	for _, command := range commands {
+		args := strings.Fields(command)
+		fmt.Fprintf(&b, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	_, err := conn.Write([]byte(b.String()))
+	assert.NoError(t, err)
+}
+
+// read reads n lines of replies.
+func read(t *testing.T, r *bufio.Reader, n int) string {
+	var b strings.Builder
+	for range n {
+		line, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// TestCommands checks the replies of the supported commands.
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	c := cache.New(10)
+	c.Put(ctx, "json", map[string]any{"a": 1.0}, 0)
+	_, conn := serve(t, c)
+	r := bufio.NewReader(conn)
+
+	tests := []struct {
+		command string
+		reply   string
+	}{
+		{"PING", "+PONG\r\n"},
+		{"ping hello", "$5\r\nhello\r\n"},
+		{"GET missing", "$-1\r\n"},
+		{"SET a 1", "+OK\r\n"},
+		{"GET a", "$1\r\n1\r\n"},
+		{"GET json", "$7\r\n{\"a\":1}\r\n"},
+		{"SET a 2 NX", "$-1\r\n"},
+		{"SET b 2 XX", "$-1\r\n"},
+		{"SET b 2 NX EX 100", "+OK\r\n"},
+		{"SET a 3 XX PX 100000", "+OK\r\n"},
+		{"SET a 3 EX 0", "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET a 3 EX 9223372037", "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET a 3 PX 9223372036855", "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET a 3 NX XX", "-ERR syntax error\r\n"},
+		{"SET a 3 EX", "-ERR syntax error\r\n"},
+		{"TTL a", ":100\r\n"},
+		{"PTTL missing", ":-2\r\n"},
+		{"TTL json", ":-1\r\n"},
+		{"TTL missing", ":-2\r\n"},
+		{"EXPIRE json 50", ":1\r\n"},
+		{"TTL json", ":50\r\n"},
+		{"EXPIRE missing 50", ":0\r\n"},
+		{"EXPIRE json 9223372037", "-ERR invalid expire time in 'expire' command\r\n"},
+		{"TTL json", ":50\r\n"},
+		{"EXISTS a b a missing", ":3\r\n"},
+		{"KEYS [ab]", "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"KEYS j*", "*1\r\n$4\r\njson\r\n"},
+		{"DBSIZE", ":3\r\n"},
+		{"DEL a missing json", ":2\r\n"},
+		{"EXPIRE b 0", ":1\r\n"},
+		{"DBSIZE", ":0\r\n"},
+		{"SET c 3", "+OK\r\n"},
+		{"FLUSHALL", "+OK\r\n"},
+		{"KEYS *", "*0\r\n"},
+		{"GET", "-ERR wrong number of arguments for 'get' command\r\n"},
+		{"NOPE x", "-ERR unknown command 'NOPE', with args beginning with: 'x' \r\n"},
+		{"QUIT", "+OK\r\n"},
+	}
+	for _, test := range tests {
+		send(t, conn, test.command)
+		assert.Equal(t, test.reply, read(t, r, strings.Count(test.reply, "\n")), test.command)
+	}
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// TestKeysSkipsExpired checks that KEYS leaves out the keys that have expired but were not removed yet,
+// which GET reports missing.
+func TestKeysSkipsExpired(t *testing.T) {
+	ctx := context.Background()
+	c := cache.New(10)
+	c.Put(ctx, "expired", "1", time.Millisecond)
+	c.Put(ctx, "live", "2", 0)
+	time.Sleep(5 * time.Millisecond)
+	_, conn := serve(t, c)
+	r := bufio.NewReader(conn)
+
+	send(t, conn, "KEYS *", "GET expired")
+	assert.Equal(t, "*1\r\n$4\r\nlive\r\n$-1\r\n", read(t, r, 4))
+}
+
+// TestPipelineAndRESP3 checks that pipelined and inline commands are answered in order,
+// and that HELLO 3 switches the replies to RESP3.
+func TestPipelineAndRESP3(t *testing.T) {
+	c := cache.New(10)
+	_, conn := serve(t, c)
+	r := bufio.NewReader(conn)
+
+	send(t, conn, "SET a 1", "GET a", "GET b")
+	assert.Equal(t, "+OK\r\n$1\r\n1\r\n$-1\r\n", read(t, r, 4))
+
+	_, err := conn.Write([]byte("DEL a\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, ":1\r\n", read(t, r, 1))
+
+	send(t, conn, "HELLO 3")
+	reply := read(t, r, 1)
+	assert.Equal(t, "%7\r\n", reply)
+	read(t, r, 25)
+	send(t, conn, "GET a")
+	assert.Equal(t, "_\r\n", read(t, r, 1))
+}
+
+// TestShutdown checks that Shutdown closes idle connections and stops accepting new ones.
+func TestShutdown(t *testing.T) {
+	c := cache.New(10)
+	s, conn := serve(t, c)
+	r := bufio.NewReader(conn)
+
+	send(t, conn, "PING")
+	assert.Equal(t, "+PONG\r\n", read(t, r, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, s.Shutdown(ctx))
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = net.Dial("tcp", conn.RemoteAddr().String())
+	assert.Error(t, err)
+}
+
+// TestAnnouncedLengths checks that the lengths a command announces are not allocated before its data arrives.
+func TestAnnouncedLengths(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for range 10 {
+		r := bufio.NewReader(strings.NewReader("*1048576\r\n$536870912\r\nabc"))
+		_, err := readCommand(r)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+	runtime.ReadMemStats(&after)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64<<20))
+
+	args, err := readCommand(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$1\r\na\r\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("GET"), []byte("a")}, args)
+}
@@ -0,0 +1,156 @@
+// Package snapshot writes the contents of a cache to a file and reads them back.
+//
+// A snapshot file starts with the magic bytes "LRUS", a big-endian uint16 format version
+// and the uint64 length of the payload, followed by the payload and its CRC-32 (Castagnoli) checksum.
+// The payload is a JSON array of entries ordered from the least to the most recently used one,
+// each with its key, value and absolute expiration time in Unix nanoseconds, zero if it never expires.
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"lru-cache/pkg/errs"
+	"lru-cache/pkg/lru"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Version is the format version written by Encode.
+const Version = 1
+
+var (
+	magic = [4]byte{'L', 'R', 'U', 'S'}
+	table = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type header struct {
+	Magic   [4]byte
+	Version uint16
+	Length  uint64
+}
+
+type record struct {
+	Key       string `json:"key"`
+	Value     any    `json:"value"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// Encode writes the entries to w in the snapshot format.
+func Encode(w io.Writer, entries []lru.Entry[string, any]) error {
+	records := make([]record, len(entries))
+	for i, e := range entries {
+		records[i] = record{Key: e.Key, Value: e.Value}
+		if !e.ExpiresAt.IsZero() {
+			records[i].ExpiresAt = e.ExpiresAt.UnixNano()
+		}
+	}
+	payload, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, header{Magic: magic, Version: Version, Length: uint64(len(payload))}); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, crc32.Checksum(payload, table))
+}
+
+// Decode reads entries written by Encode from r. It returns errs.ErrCorruptSnapshot if the data
+// is truncated or does not match its checksum, and errs.ErrSnapshotVersion if it was written in another version.
+func Decode(r io.Reader) ([]lru.Entry[string, any], error) {
+	var h header
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return nil, corrupt(err)
+	}
+	if h.Magic != magic {
+		return nil, fmt.Errorf("%w: not a snapshot", errs.ErrCorruptSnapshot)
+	}
+	if h.Version != Version {
+		return nil, fmt.Errorf("%w: %d", errs.ErrSnapshotVersion, h.Version)
+	}
+	payload, err := io.ReadAll(io.LimitReader(r, int64(h.Length)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(payload)) != h.Length {
+		return nil, corrupt(io.ErrUnexpectedEOF)
+	}
+	var sum uint32
+	if err := binary.Read(r, binary.BigEndian, &sum); err != nil {
+		return nil, corrupt(err)
+	}
+	if sum != crc32.Checksum(payload, table) {
+		return nil, fmt.Errorf("%w: checksum mismatch", errs.ErrCorruptSnapshot)
+	}
+
+	var records []record
+	if err := json.Unmarshal(payload, &records); err != nil {
+		return nil, corrupt(err)
+	}
+	entries := make([]lru.Entry[string, any], len(records))
+	for i, rec := range records {
+		entries[i] = lru.Entry[string, any]{Key: rec.Key, Value: rec.Value}
+		if rec.ExpiresAt != 0 {
+			entries[i].ExpiresAt = time.Unix(0, rec.ExpiresAt)
+		}
+	}
+	return entries, nil
+}
+
+func corrupt(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated", errs.ErrCorruptSnapshot)
+	}
+	return fmt.Errorf("%w: %w", errs.ErrCorruptSnapshot, err)
+}
+
+// Save writes the entries to the file at path. The file is replaced atomically,
+// so a crash while saving leaves the previous snapshot intact.
+func Save(path string, entries []lru.Entry[string, any]) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := Encode(w, entries); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads the entries from the file at path. A missing file is not an error and yields no entries.
+func Load(path string) ([]lru.Entry[string, any], error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(bufio.NewReader(f))
+}
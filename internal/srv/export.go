@@ -0,0 +1,131 @@
+package srv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"lru-cache/internal/cache"
+	"lru-cache/internal/models"
+	"lru-cache/pkg/lru"
+)
+
+// exportEntries streams the entries of the cache as newline-delimited JSON, from the least to the most
+// recently used one, reading them page by page so that neither the cache nor the response is held at once.
+// An entry used while the export runs may be exported twice, the later line being the more recent one.
+func (s *Server) exportEntries(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	scanner, ok := s.storage.(cache.Scanner)
+	if !ok {
+		s.logger.Debug("Cache doesn't list entries page by page")
+		http.Error(rw, "Not implemented", http.StatusNotImplemented)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.WriteHeader(http.StatusOK)
+	e := json.NewEncoder(rw)
+	rc := http.NewResponseController(rw)
+	rank, cursor := 0, ""
+	for {
+		entries, next, err := scanner.Scan(ctx, cursor, maxBatch, lru.LeastRecent, nil)
+		if err != nil {
+			s.logger.Warn("Something went wrong in export", slog.Any("error", err))
+			return
+		}
+		for _, entry := range entries {
+			line := models.ExportEntry{Key: entry.Key, Value: entry.Value, Rank: rank}
+			if !entry.ExpiresAt.IsZero() {
+				line.ExpiresAt = int(entry.ExpiresAt.Unix())
+			}
+			if err := e.Encode(line); err != nil {
+				s.logger.Debug("Export interrupted", slog.Any("error", err))
+				return
+			}
+			rank++
+		}
+		rc.Flush()
+		if next == "" || ctx.Err() != nil {
+			break
+		}
+		cursor = next
+	}
+
+	s.logger.Debug("Exported entries", slog.Int("entries", rank))
+}
+
+// importEntries loads a stream of entries written by exportEntries in its order, so that the last line
+// ends up as the most recently used entry. Entries keep their expiration time, the ones without it never
+// expire and the ones that have already expired are skipped. Entries are stored in batches as they are read:
+// if a line is malformed, the entries before it stay imported.
+func (s *Server) importEntries(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	d := json.NewDecoder(r.Body)
+	data := &models.ImportResponse{}
+	keys := make([]string, 0, maxBatch)
+	values := make([]any, 0, maxBatch)
+	ttls := make([]time.Duration, 0, maxBatch)
+	flush := func() error {
+		stored, err := cache.PutMany(ctx, s.storage, keys, values, ttls)
+		for _, ok := range stored {
+			if ok {
+				data.Imported++
+			}
+		}
+		keys, values, ttls = keys[:0], values[:0], ttls[:0]
+		return err
+	}
+
+	for line := 1; ; line++ {
+		var entry models.ExportEntry
+		err := d.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.logger.Debug("Unable to unmarshall JSON in import", slog.Int("line", line), slog.Any("error", err))
+			if err := flush(); err != nil {
+				s.logger.Warn("Something went wrong in import", slog.Any("error", err))
+			}
+			http.Error(rw, fmt.Sprintf("Invalid entry on line %d, %d entries imported", line, data.Imported), http.StatusBadRequest)
+			return
+		}
+
+		var ttl time.Duration
+		if entry.ExpiresAt > 0 {
+			if ttl = time.Until(time.Unix(int64(entry.ExpiresAt), 0)); ttl <= 0 {
+				data.Expired++
+				continue
+			}
+		}
+		keys, values, ttls = append(keys, entry.Key), append(values, entry.Value), append(ttls, ttl)
+		if len(keys) < maxBatch {
+			continue
+		}
+		if err := flush(); err != nil {
+			s.logger.Warn("Something went wrong in import", slog.Any("error", err))
+			http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := flush(); err != nil {
+		s.logger.Warn("Something went wrong in import", slog.Any("error", err))
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := data.ToJSON(rw); err != nil {
+		s.logger.Warn("Unable to marshall data into JSON in import")
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("Imported entries", slog.Int("entries", data.Imported), slog.Int("expired", data.Expired))
+}
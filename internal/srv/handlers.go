@@ -1,16 +1,43 @@
 package srv
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"lru-cache/internal/cache"
 	"lru-cache/internal/models"
 	"lru-cache/pkg/errs"
+	"lru-cache/pkg/glob"
+	"lru-cache/pkg/lru"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// maxBatch is the maximum number of keys of a batch request, and of a page of entries.
+const maxBatch = 1000
+
+// defaultLimit is the number of entries of a page when the request sets no limit.
+const defaultLimit = 100
+
+// keyParam returns the key of the route. chi routes by the escaped path when it holds escaped characters
+// such as %2F, in which case the key is unescaped.
+func keyParam(r *http.Request) string {
+	key := chi.URLParam(r, "key")
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(key); err == nil {
+			return unescaped
+		}
+	}
+	return key
+}
+
 func (s *Server) postKey(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -21,30 +48,145 @@ func (s *Server) postKey(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	cond, err := writeCondition(r, data)
+	if err != nil {
+		s.logger.Debug("Invalid condition in post key", slog.Any("error", err))
+		http.Error(rw, "Invalid condition", http.StatusBadRequest)
+		return
+	}
 
 	ttl := s.cfg.DefaultTTL
 	if data.TTLSeconds > 0 {
 		ttl = time.Duration(data.TTLSeconds) * time.Second
 	}
 
-	err = s.storage.Put(ctx, data.Key, data.Value, ttl)
+	versioner, versioned := s.storage.(cache.Versioner)
+	stored, version := true, uint64(0)
+	switch {
+	case cond.version > 0 && !versioned:
+		s.logger.Debug("Cache doesn't version entries")
+		http.Error(rw, "Not implemented", http.StatusNotImplemented)
+		return
+	case cond.version > 0:
+		version, stored, err = versioner.PutIfVersion(ctx, data.Key, data.Value, ttl, cond.version)
+	case cond.mode != 0:
+		stored, err = cache.PutIf(ctx, s.storage, data.Key, data.Value, ttl, cond.mode)
+	default:
+		err = s.storage.Put(ctx, data.Key, data.Value, ttl)
+	}
 	if err != nil {
 		s.logger.Warn("Something went wrong in post a key", slog.Any("error", err))
 		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
+	if !stored {
+		s.logger.Debug("Condition not met in post key", slog.String("key", data.Key))
+		http.Error(rw, "Condition not met", cond.conflict)
+		return
+	}
 
+	if version == 0 && versioned {
+		version, _ = versioner.Version(ctx, data.Key)
+	}
+	if version > 0 {
+		rw.Header().Set("ETag", etag(version))
+	}
 	rw.WriteHeader(http.StatusCreated)
 
 	s.logger.Debug("Created a key", slog.String("key", data.Key), slog.Any("value", data.Value), slog.Duration("ttl", ttl))
 }
 
+// condition is the condition under which postKey stores a value.
+type condition struct {
+	// mode is the condition on the presence of the key, 0 to store unconditionally.
+	mode lru.Mode
+	// version is the version the entry must have, 0 for none.
+	version uint64
+	// conflict is the status code replied when the condition does not hold.
+	conflict int
+}
+
+// writeCondition reads the condition of a write either from the body, answered with 409 Conflict when
+// it does not hold, or from the If-Match and If-None-Match headers, answered with 412 Precondition Failed.
+func writeCondition(r *http.Request, data *models.PostRequest) (condition, error) {
+	cond := condition{conflict: http.StatusConflict}
+	switch data.Mode {
+	case "":
+		cond.version = data.IfVersion
+	case "if_absent":
+		cond.mode = lru.IfAbsent
+	case "if_present":
+		cond.mode = lru.IfPresent
+	case "if_version":
+		cond.version = data.IfVersion
+		if cond.version == 0 {
+			return cond, errors.New("if_version mode without if_version")
+		}
+	default:
+		return cond, fmt.Errorf("unknown mode %q", data.Mode)
+	}
+	if cond.mode != 0 && data.IfVersion != 0 {
+		return cond, fmt.Errorf("if_version with mode %q", data.Mode)
+	}
+
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		return cond, nil
+	}
+	if cond.mode != 0 || cond.version != 0 || (ifMatch != "" && ifNoneMatch != "") {
+		return cond, errors.New("several conditions")
+	}
+	cond.conflict = http.StatusPreconditionFailed
+	switch {
+	case strings.TrimSpace(ifNoneMatch) == "*":
+		cond.mode = lru.IfAbsent
+	case ifNoneMatch != "":
+		return cond, fmt.Errorf("unsupported If-None-Match %q", ifNoneMatch)
+	case strings.TrimSpace(ifMatch) == "*":
+		cond.mode = lru.IfPresent
+	default:
+		version, ok := parseETag(ifMatch)
+		if !ok {
+			return cond, fmt.Errorf("unsupported If-Match %q", ifMatch)
+		}
+		cond.version = version
+	}
+	return cond, nil
+}
+
+// etag returns the entity tag of a version of an entry.
+func etag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// parseETag returns the version of a single strong entity tag returned by etag.
+func parseETag(tag string) (uint64, bool) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+	return version, err == nil && version > 0
+}
+
+// noneMatch reports whether an If-None-Match header matches the entity tag, comparing weakly.
+func noneMatch(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) getKey(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	key := chi.URLParam(r, "key")
+	key := keyParam(r)
 	data := &models.GetResponse{Key: key}
-	var err error
-	data.Value, data.TimeExpiresAt, err = s.storage.Get(ctx, key)
+	item, err := cache.GetItem(ctx, s.storage, key)
+	data.Value, data.TimeExpiresAt, data.Stale, data.Refreshing = item.Value, item.ExpiresAt, item.Stale, item.Refreshing
+	data.Version = item.Version
 	if err != nil {
 		if err == errs.ErrNotFound {
 			s.logger.Debug("Key not found in get by key", slog.String("key", key))
@@ -56,6 +198,15 @@ func (s *Server) getKey(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if data.Version > 0 {
+		tag := etag(data.Version)
+		rw.Header().Set("ETag", tag)
+		if noneMatch(r.Header.Get("If-None-Match"), tag) {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	rw.Header().Set("Content-Type", "application/json")
 	rw.WriteHeader(http.StatusOK)
 	if err := data.ToJSON(rw); err != nil {
 		s.logger.Error("Failed to marshall struct into JSON", slog.String("key", key))
@@ -67,6 +218,11 @@ func (s *Server) getKey(rw http.ResponseWriter, r *http.Request) {
 
 func (s *Server) getAllKeys(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	query := r.URL.Query()
+	if query.Has("limit") || query.Has("cursor") || query.Has("match") || query.Has("order") {
+		s.scanKeys(rw, r)
+		return
+	}
 	data := &models.GetAllResponse{}
 
 	var err error
@@ -81,7 +237,11 @@ func (s *Server) getAllKeys(rw http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if stater, ok := s.storage.(cache.Stater); ok {
+		data.Weight = stater.Stats().Weight
+	}
 
+	rw.Header().Set("Content-Type", "application/json")
 	rw.WriteHeader(http.StatusOK)
 	if err := data.ToJSON(rw); err != nil {
 		s.logger.Warn("Unable to marshall data into JSON in get all keys")
@@ -91,13 +251,79 @@ func (s *Server) getAllKeys(rw http.ResponseWriter, r *http.Request) {
 	s.logger.Debug("Got all keys")
 }
 
+// scanKeys serves a page of the entries of the cache when getAllKeys is called with a listing parameter.
+func (s *Server) scanKeys(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	scanner, ok := s.storage.(cache.Scanner)
+	if !ok {
+		s.logger.Debug("Cache doesn't list entries page by page")
+		http.Error(rw, "Not implemented", http.StatusNotImplemented)
+		return
+	}
+
+	limit := defaultLimit
+	if query.Has("limit") {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > maxBatch {
+			s.logger.Debug("Invalid limit in get all keys", slog.String("limit", query.Get("limit")))
+			http.Error(rw, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	var order lru.Order
+	switch query.Get("order") {
+	case "", "lru":
+		order = lru.LeastRecent
+	case "mru":
+		order = lru.MostRecent
+	default:
+		s.logger.Debug("Invalid order in get all keys", slog.String("order", query.Get("order")))
+		http.Error(rw, "Invalid order", http.StatusBadRequest)
+		return
+	}
+	var match func(string) bool
+	if pattern := query.Get("match"); pattern != "" {
+		match = func(key string) bool {
+			return glob.Match(pattern, key)
+		}
+	}
+
+	entries, next, err := scanner.Scan(ctx, query.Get("cursor"), limit, order, match)
+	if err != nil {
+		if err == errs.ErrInvalidCursor {
+			s.logger.Debug("Invalid cursor in get all keys", slog.String("cursor", query.Get("cursor")))
+			http.Error(rw, "Invalid cursor", http.StatusBadRequest)
+		} else {
+			s.logger.Warn("Something went wrong in get all keys", slog.Any("error", err))
+			http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+		}
+		return
+	}
+	data := &models.ScanResponse{Entries: make([]models.ScanEntry, len(entries)), NextCursor: next}
+	for i, e := range entries {
+		data.Entries[i] = models.ScanEntry{Key: e.Key, Value: e.Value, TimeExpiresAt: e.ExpiresAt}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := data.ToJSON(rw); err != nil {
+		s.logger.Warn("Unable to marshall data into JSON in get all keys")
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("Got a page of keys", slog.Int("keys", len(entries)))
+}
+
 func (s *Server) evictKey(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	key := chi.URLParamFromCtx(ctx, "key")
+	key := keyParam(r)
 
 	value, err := s.storage.Evict(ctx, key)
 	if err != nil {
-		if err == errs.ErrNotFound {
+		if err == errs.ErrNotFound || err == errs.ErrCacheIsEmpty {
 			s.logger.Debug("Key not found in delete by key", slog.String("key", key))
 			http.Error(rw, "Not found", http.StatusNotFound)
 		} else {
@@ -111,6 +337,69 @@ func (s *Server) evictKey(rw http.ResponseWriter, r *http.Request) {
 	s.logger.Debug("Deleted a key", slog.String("key", key), slog.Any("value", value))
 }
 
+// incrConflicts are the messages replied with 409 Conflict when a value can't be incremented.
+var incrConflicts = map[error]string{
+	errs.ErrNotNumeric: "Value is not a number",
+	errs.ErrNotInteger: "Value is not an integer",
+	errs.ErrOverflow:   "Increment would overflow",
+}
+
+func (s *Server) incrKey(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := keyParam(r)
+
+	req := &models.IncrRequest{}
+	if err := req.FromJSON(r.Body); err != nil && !errors.Is(err, io.EOF) {
+		s.logger.Debug("Unable to unmarshall JSON in incr key", slog.Any("error", err))
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	ttl := s.cfg.DefaultTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	// An integer delta keeps integer counters, any other number makes the value a float.
+	data := &models.IncrResponse{Key: key}
+	var err error
+	if req.Delta == "" {
+		req.Delta = "1"
+	}
+	if delta, perr := strconv.ParseInt(string(req.Delta), 10, 64); perr == nil {
+		data.Value, data.TimeExpiresAt, err = cache.Incr(ctx, s.storage, key, delta, ttl)
+	} else if delta, perr := req.Delta.Float64(); perr == nil {
+		data.Value, data.TimeExpiresAt, err = cache.IncrFloat(ctx, s.storage, key, delta, ttl)
+	} else {
+		s.logger.Debug("Invalid delta in incr key", slog.String("delta", string(req.Delta)))
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg, ok := incrConflicts[err]; ok {
+		s.logger.Debug("Unable to increment key", slog.String("key", key), slog.Any("error", err))
+		http.Error(rw, msg, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errs.ErrNotStored) {
+		s.logger.Debug("Incremented value not stored", slog.String("key", key))
+		http.Error(rw, "Value could not be stored", http.StatusInsufficientStorage)
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Something went wrong in incr key", slog.String("key", key), slog.Any("error", err))
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := data.ToJSON(rw); err != nil {
+		s.logger.Warn("Unable to marshall data into JSON in incr key")
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("Incremented a key", slog.String("key", key), slog.Any("value", data.Value))
+}
+
 func (s *Server) evictAllKeys(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -129,3 +418,145 @@ func (s *Server) evictAllKeys(rw http.ResponseWriter, r *http.Request) {
 
 	s.logger.Debug("Deleted all keys")
 }
+
+func (s *Server) getStats(rw http.ResponseWriter, r *http.Request) {
+	stater, ok := s.storage.(cache.Stater)
+	if !ok {
+		s.logger.Debug("Cache doesn't report stats")
+		http.Error(rw, "Not implemented", http.StatusNotImplemented)
+		return
+	}
+	st := stater.Stats()
+	data := &models.StatsResponse{
+		Hits:        st.Hits,
+		Misses:      st.Misses,
+		HitRatio:    st.HitRatio(),
+		Puts:        st.Puts,
+		Evictions:   st.Evictions,
+		Expirations: st.Expirations,
+		Removals:    st.Removals,
+		Admitted:    st.Admitted,
+		Rejected:    st.Rejected,
+		Size:        st.Size,
+		Capacity:    st.Capacity,
+		Weight:      st.Weight,
+		MaxWeight:   st.MaxWeight,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := data.ToJSON(rw); err != nil {
+		s.logger.Warn("Unable to marshall data into JSON in get stats")
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("Got stats")
+}
+
+func (s *Server) mget(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req := &models.BatchRequest{}
+	if err := req.FromJSON(r.Body); err != nil || len(req.Keys) > maxBatch {
+		s.logger.Debug("Invalid request body in mget", slog.Any("error", err), slog.Int("keys", len(req.Keys)))
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	results, err := cache.GetMany(ctx, s.storage, req.Keys)
+	if err != nil {
+		s.logger.Warn("Something went wrong in mget", slog.Any("error", err))
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	data := &models.BatchResponse{Results: make([]models.BatchResult, len(results))}
+	for i, res := range results {
+		data.Results[i] = models.BatchResult{Key: req.Keys[i], Found: res.Err == nil}
+		if res.Err == nil {
+			item := res.Item
+			data.Results[i].Value, data.Results[i].TimeExpiresAt = item.Value, item.ExpiresAt
+			data.Results[i].Stale, data.Results[i].Refreshing = item.Stale, item.Refreshing
+			data.Results[i].Version = item.Version
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := data.ToJSON(rw); err != nil {
+		s.logger.Warn("Unable to marshall data into JSON in mget")
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("Got keys", slog.Int("keys", len(req.Keys)))
+}
+
+func (s *Server) mset(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req := &models.MSetRequest{}
+	if err := req.FromJSON(r.Body); err != nil || len(req.Entries) > maxBatch {
+		s.logger.Debug("Invalid request body in mset", slog.Any("error", err), slog.Int("entries", len(req.Entries)))
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	keys := make([]string, len(req.Entries))
+	values := make([]any, len(req.Entries))
+	ttls := make([]time.Duration, len(req.Entries))
+	for i, entry := range req.Entries {
+		keys[i], values[i], ttls[i] = entry.Key, entry.Value, s.cfg.DefaultTTL
+		if entry.TTLSeconds > 0 {
+			ttls[i] = time.Duration(entry.TTLSeconds) * time.Second
+		}
+	}
+	stored, err := cache.PutMany(ctx, s.storage, keys, values, ttls)
+	if err != nil {
+		s.logger.Warn("Something went wrong in mset", slog.Any("error", err))
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	data := &models.MSetResponse{Results: make([]models.MSetResult, len(keys))}
+	for i, key := range keys {
+		data.Results[i] = models.MSetResult{Key: key, Stored: stored[i]}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := data.ToJSON(rw); err != nil {
+		s.logger.Warn("Unable to marshall data into JSON in mset")
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("Created keys", slog.Int("keys", len(keys)))
+}
+
+func (s *Server) mdel(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req := &models.BatchRequest{}
+	if err := req.FromJSON(r.Body); err != nil || len(req.Keys) > maxBatch {
+		s.logger.Debug("Invalid request body in mdel", slog.Any("error", err), slog.Int("keys", len(req.Keys)))
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	results, err := cache.EvictMany(ctx, s.storage, req.Keys)
+	if err != nil {
+		s.logger.Warn("Something went wrong in mdel", slog.Any("error", err))
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	data := &models.BatchResponse{Results: make([]models.BatchResult, len(results))}
+	for i, res := range results {
+		data.Results[i] = models.BatchResult{Key: req.Keys[i], Found: res.Err == nil}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := data.ToJSON(rw); err != nil {
+		s.logger.Warn("Unable to marshall data into JSON in mdel")
+		http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("Deleted keys", slog.Int("keys", len(req.Keys)))
+}
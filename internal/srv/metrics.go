@@ -0,0 +1,49 @@
+package srv
+
+import (
+	"lru-cache/internal/cache"
+	"lru-cache/internal/metrics"
+)
+
+// serverMetrics holds the metrics exposed by the server at /metrics.
+type serverMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+// newServerMetrics registers the HTTP request metrics and, if the storage reports statistics, the cache metrics.
+func newServerMetrics(storage cache.ILRUCache) *serverMetrics {
+	registry := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: registry,
+		requests: registry.NewCounterVec("http_requests_total",
+			"Total number of HTTP requests handled.", "route", "method", "status"),
+		duration: registry.NewHistogramVec("http_request_duration_seconds",
+			"Time spent handling HTTP requests in seconds.", metrics.DefaultBuckets, "route", "method", "status"),
+	}
+
+	stater, ok := storage.(cache.Stater)
+	if !ok {
+		return m
+	}
+	registry.NewGaugeFunc("lru_cache_size", "Number of entries stored in the cache.", func() float64 {
+		return float64(stater.Stats().Size)
+	})
+	registry.NewGaugeFunc("lru_cache_capacity", "Maximum number of entries in the cache, zero if bounded by bytes.", func() float64 {
+		return float64(max(stater.Stats().Capacity, 0))
+	})
+	registry.NewCounterFunc("lru_cache_hits_total", "Number of reads that found a live entry.", func() float64 {
+		return float64(stater.Stats().Hits)
+	})
+	registry.NewCounterFunc("lru_cache_misses_total", "Number of reads that found no live entry.", func() float64 {
+		return float64(stater.Stats().Misses)
+	})
+	registry.NewCounterFunc("lru_cache_evictions_total", "Number of entries evicted to make room for others.", func() float64 {
+		return float64(stater.Stats().Evictions)
+	})
+	registry.NewCounterFunc("lru_cache_expirations_total", "Number of entries removed because their TTL had passed.", func() float64 {
+		return float64(stater.Stats().Expirations)
+	})
+	return m
+}
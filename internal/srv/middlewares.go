@@ -3,7 +3,11 @@ package srv
 import (
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
@@ -15,3 +19,26 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 		s.logger.Debug("handled request",slog.Time("time", time.Now()),slog.String("method",r.Method),slog.String("URI", r.RequestURI), slog.Duration("handling time", time.Since(start)))
 	})
 }
+
+// metricsMiddleware counts requests and measures their latency by route pattern, method and status code.
+// The route pattern rather than the URI is used as a label to keep the number of series bounded.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := []string{route, r.Method, strconv.Itoa(status)}
+		s.metrics.requests.WithLabelValues(labels...).Inc()
+		s.metrics.duration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,16 @@
+package srv
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPI is the OpenAPI document describing the routes under /api/lru.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+func (s *Server) getOpenAPI(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(openAPI)
+}
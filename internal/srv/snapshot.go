@@ -0,0 +1,56 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"lru-cache/internal/cache"
+	"lru-cache/internal/snapshot"
+	"time"
+)
+
+// restoreSnapshot loads the snapshot file into the storage. A corrupt snapshot is reported and ignored,
+// so that the server starts with an empty cache rather than with part of the data.
+func (s *Server) restoreSnapshot() {
+	snapshotter, ok := s.storage.(cache.Snapshotter)
+	if !ok {
+		s.logger.Warn("Storage does not support snapshots")
+		return
+	}
+	entries, err := snapshot.Load(s.cfg.SnapshotPath)
+	if err != nil {
+		s.logger.Error("Failed to load snapshot", slog.String("path", s.cfg.SnapshotPath), slog.Any("error", err))
+		return
+	}
+	snapshotter.Restore(entries)
+	s.logger.Info("Restored snapshot", slog.String("path", s.cfg.SnapshotPath), slog.Int("entries", len(entries)))
+}
+
+// saveSnapshot writes the contents of the storage to the snapshot file.
+func (s *Server) saveSnapshot() {
+	snapshotter, ok := s.storage.(cache.Snapshotter)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	entries := snapshotter.Snapshot()
+	if err := snapshot.Save(s.cfg.SnapshotPath, entries); err != nil {
+		s.logger.Error("Failed to save snapshot", slog.String("path", s.cfg.SnapshotPath), slog.Any("error", err))
+		return
+	}
+	s.logger.Debug("Saved snapshot", slog.Int("entries", len(entries)), slog.Duration("took", time.Since(start)))
+}
+
+// snapshotLoop saves a snapshot every SnapshotInterval until ctx is done.
+func (s *Server) snapshotLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.saveSnapshot()
+		}
+	}
+}
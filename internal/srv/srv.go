@@ -5,33 +5,84 @@ package srv
 import (
 	"context"
 	"errors"
+	"io"
 	"log/slog"
+	"lru-cache/internal/aof"
 	"lru-cache/internal/cache"
+	"lru-cache/internal/grpcsrv"
+	"lru-cache/internal/memcache"
+	"lru-cache/internal/resp"
+	"lru-cache/internal/tcpsrv"
 	"lru-cache/pkg/levelhandler"
+	"lru-cache/pkg/lru"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
 )
 
 // Server defines a configured server with storage, router, configuration, and logger.
 type Server struct {
 	storage cache.ILRUCache
 	router  chi.Router
+	routes  sync.Once
 	cfg     Config
 	logger  *slog.Logger
+	metrics *serverMetrics
+	aof     *aof.Log
 }
 
 // Config holds the configuration parameters for the server.
 type Config struct {
-	HostPort   string        `env:"SERVER_HOST_PORT" envDefault:"localhost:8080"`
-	CacheSize  int           `env:"CACHE_SIZE" envDefault:"10"`
-	DefaultTTL time.Duration `env:"DEFAULT_CACHE_TTL" envDefault:"1m"`
-	LogLevel   string        `env:"LOG_LEVEL" envDefault:"WARN"`
+	HostPort    string `env:"SERVER_HOST_PORT" envDefault:"localhost:8080"`
+	CacheSize   int    `env:"CACHE_SIZE" envDefault:"10"`
+	CacheShards int    `env:"CACHE_SHARDS" envDefault:"1"`
+	// CacheMaxBytes bounds the cache by the estimated size of its entries instead of CacheSize when positive.
+	CacheMaxBytes int64 `env:"CACHE_MAX_BYTES" envDefault:"0"`
+	// EvictionPolicy is one of lru, lfu, sieve, 2q or arc.
+	EvictionPolicy string `env:"EVICTION_POLICY" envDefault:"lru"`
+	// CacheAdmission enables the TinyLFU admission filter in front of the eviction policy.
+	CacheAdmission bool          `env:"CACHE_ADMISSION" envDefault:"false"`
+	DefaultTTL     time.Duration `env:"DEFAULT_CACHE_TTL" envDefault:"1m"`
+	LogLevel       string        `env:"LOG_LEVEL" envDefault:"WARN"`
+	// ExpireInterval enables background TTL expiration when positive.
+	ExpireInterval  time.Duration `env:"CACHE_EXPIRE_INTERVAL" envDefault:"0s"`
+	ExpireSamples   int           `env:"CACHE_EXPIRE_SAMPLES" envDefault:"20"`
+	ExpireThreshold float64       `env:"CACHE_EXPIRE_THRESHOLD" envDefault:"0.25"`
+	// ExpireWheelTick enables timing wheel TTL expiration with the given precision when positive.
+	ExpireWheelTick time.Duration `env:"CACHE_EXPIRE_WHEEL_TICK" envDefault:"0s"`
+	// StoreDir puts the cache in front of a file store in the directory when set.
+	StoreDir string `env:"STORE_DIR"`
+	// StoreWriteBehind queues writes to the store instead of writing through.
+	StoreWriteBehind   bool          `env:"STORE_WRITE_BEHIND" envDefault:"false"`
+	StoreFlushInterval time.Duration `env:"STORE_FLUSH_INTERVAL" envDefault:"1s"`
+	// RefreshAhead reloads entries from the store when read within this last fraction of their TTL, in (0, 1].
+	RefreshAhead float64 `env:"CACHE_REFRESH_AHEAD" envDefault:"0"`
+	// StaleGrace serves expired entries for this long while they are reloaded from the store.
+	StaleGrace time.Duration `env:"CACHE_STALE_GRACE" envDefault:"0s"`
+	// SnapshotPath is the file the cache is restored from at startup and saved to on shutdown when set.
+	SnapshotPath string `env:"SNAPSHOT_PATH"`
+	// SnapshotInterval additionally saves a snapshot periodically when positive.
+	SnapshotInterval time.Duration `env:"SNAPSHOT_INTERVAL" envDefault:"0s"`
+	// AOFPath is the append-only log the cache is rebuilt from at startup when set.
+	// It replaces the snapshot, which is then neither restored nor saved.
+	AOFPath string `env:"AOF_PATH"`
+	// AOFFsync is one of always, everysec or never.
+	AOFFsync       string `env:"AOF_FSYNC" envDefault:"everysec"`
+	AOFRewriteSize int64  `env:"AOF_REWRITE_SIZE" envDefault:"67108864"`
+	// RESPHostPort additionally serves the cache to Redis clients on the address when set.
+	RESPHostPort string `env:"RESP_HOST_PORT"`
+	// MemcacheHostPort additionally serves the cache to memcached clients on the address when set.
+	MemcacheHostPort string `env:"MEMCACHE_HOST_PORT"`
+	// GRPCHostPort additionally serves the cache over gRPC on the address when set.
+	GRPCHostPort string `env:"GRPC_HOST_PORT"`
 }
 
 // New creates a new Server with the provided configuration.
@@ -45,44 +96,255 @@ func New(cfg Config) (*Server, error) {
 
 	logger := slog.New(levelhandler)
 
-	storage := cache.New(cfg.CacheSize)
-	logger.Info("Created LRU cache", slog.Int("size", cfg.CacheSize))
+	policy, err := lru.ParsePolicy(cfg.EvictionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	opts := []lru.Option[string, any]{lru.WithPolicy[string, any](policy)}
+	if cfg.CacheAdmission {
+		opts = append(opts, cache.WithAdmission())
+		logger.Info("Enabled TinyLFU admission")
+	}
+	if cfg.ExpireInterval > 0 {
+		opts = append(opts, lru.WithActiveExpiration[string, any](lru.ActiveExpiration{
+			Interval:   cfg.ExpireInterval,
+			SampleSize: cfg.ExpireSamples,
+			Threshold:  cfg.ExpireThreshold,
+		}))
+		logger.Info("Enabled active expiration", slog.Duration("interval", cfg.ExpireInterval))
+	}
+	if cfg.ExpireWheelTick > 0 {
+		opts = append(opts, lru.WithTimingWheel[string, any](cfg.ExpireWheelTick))
+		logger.Info("Enabled timing wheel expiration", slog.Duration("tick", cfg.ExpireWheelTick))
+	}
+
+	// The loader reloads entries from the backing store, which wraps the cache and is thus only known once it is created.
+	var reloader cache.Reloader
+	if cfg.RefreshAhead > 0 || cfg.StaleGrace > 0 {
+		if cfg.StoreDir == "" {
+			logger.Warn("Refresh-ahead and stale grace need a backing store, ignoring them")
+		} else {
+			opts = append(opts,
+				lru.WithLoader(func(ctx context.Context, key string) (any, time.Duration, error) {
+					return reloader.Reload(ctx, key)
+				}),
+				lru.WithRefreshAhead[string, any](cfg.RefreshAhead),
+				lru.WithStaleGrace[string, any](cfg.StaleGrace),
+			)
+			logger.Info("Enabled reloads from the store", slog.Float64("refresh ahead", cfg.RefreshAhead), slog.Duration("stale grace", cfg.StaleGrace))
+		}
+	}
+
+	var log *aof.Log
+	if cfg.AOFPath != "" {
+		fsync, err := aof.ParseFsync(cfg.AOFFsync)
+		if err != nil {
+			return nil, err
+		}
+		log, err = aof.Open(aof.Config{
+			Path:        cfg.AOFPath,
+			Fsync:       fsync,
+			RewriteSize: cfg.AOFRewriteSize,
+			OnError: func(err error) {
+				logger.Error("Append-only log error", slog.Any("error", err))
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, lru.WithJournal[string, any](log))
+		if cfg.SnapshotPath != "" {
+			logger.Warn("The append-only log replaces the snapshot, ignoring the snapshot path", slog.String("path", cfg.SnapshotPath))
+			cfg.SnapshotPath = ""
+		}
+	}
+
+	size := cfg.CacheSize
+	if cfg.CacheMaxBytes > 0 {
+		size = 0
+		opts = append(opts, cache.WithMaxBytes(cfg.CacheMaxBytes))
+		logger.Info("Bounded cache by size in bytes", slog.Int64("max bytes", cfg.CacheMaxBytes))
+	}
+
+	var storage cache.ILRUCache
+	if cfg.CacheShards > 1 {
+		storage = cache.NewSharded(size, cfg.CacheShards, opts...)
+	} else {
+		storage = cache.New(size, opts...)
+	}
+	logger.Info("Created LRU cache", slog.Int("size", cfg.CacheSize), slog.Int("shards", cfg.CacheShards), slog.String("policy", string(policy)))
+
+	// abort releases the cache and the log when a later step fails.
+	abort := func(err error) (*Server, error) {
+		storage.(io.Closer).Close()
+		if log != nil {
+			log.Close()
+		}
+		return nil, err
+	}
+
+	// local is the cache itself, which the log and the snapshot are loaded into without writing to the store.
+	local := storage
+	if cfg.StoreDir != "" {
+		store, err := cache.NewFileStore(cfg.StoreDir)
+		if err != nil {
+			return abort(err)
+		}
+		backed, err := cache.Backed(storage, store, cache.StoreConfig{
+			WriteBehind:   cfg.StoreWriteBehind,
+			FlushInterval: cfg.StoreFlushInterval,
+			OnError: func(key string, err error) {
+				logger.Warn("Failed to write to the store", slog.String("key", key), slog.Any("error", err))
+			},
+		})
+		if err != nil {
+			return abort(err)
+		}
+		storage = backed
+		reloader = storage.(cache.Reloader)
+		logger.Info("Backed cache by a file store", slog.String("dir", cfg.StoreDir), slog.Bool("write behind", cfg.StoreWriteBehind))
+	}
 
 	router := chi.NewRouter()
 
 	logger.Debug("Configured", slog.Any("config", cfg))
 
-	return &Server{storage: storage, router: router, cfg: cfg, logger: logger}, nil
+	s := &Server{storage: storage, router: router, cfg: cfg, logger: logger, metrics: newServerMetrics(storage), aof: log}
+	if log != nil {
+		records, err := log.Replay(context.Background(), local)
+		if err != nil {
+			return abort(err)
+		}
+		log.Start(local.(cache.Snapshotter).Snapshot)
+		logger.Info("Replayed append-only log", slog.String("path", cfg.AOFPath), slog.Int("records", records), slog.String("fsync", cfg.AOFFsync))
+	}
+	if cfg.SnapshotPath != "" {
+		s.restoreSnapshot()
+	}
+
+	return s, nil
+}
+
+// Handler returns the HTTP handler serving the API with its routes and middlewares,
+// to be served by Run or embedded in another server.
+func (s *Server) Handler() http.Handler {
+	s.routes.Do(func() {
+		s.router.Use(s.loggingMiddleware, s.metricsMiddleware, middleware.Recoverer)
+
+		s.router.Get("/metrics", s.metrics.registry.ServeHTTP)
+		s.router.Get("/api/openapi.json", s.getOpenAPI)
+
+		s.router.Route("/api/lru", func(r chi.Router) {
+			r.Post("/", s.postKey)
+			r.Post("/_mget", s.mget)
+			r.Post("/_mset", s.mset)
+			r.Post("/_mdel", s.mdel)
+			r.Get("/_stats", s.getStats)
+			r.Get("/_export", s.exportEntries)
+			r.Post("/_import", s.importEntries)
+			r.Get("/{key}", s.getKey)
+			r.Post("/{key}/_incr", s.incrKey)
+			r.Get("/", s.getAllKeys)
+			r.Delete("/{key}", s.evictKey)
+			r.Delete("/", s.evictAllKeys)
+		})
+	})
+	return s.router
 }
 
 // Run starts the server and listens for incoming HTTP requests.
-// It sets up the routes and middleware, and handles graceful shutdown on receiving a termination signal.
+// It serves the routes of Handler, and handles graceful shutdown on receiving a termination signal.
+// Every address is listened on before anything is served, so that if one of them can't be used
+// Run returns the error without having served any request, after closing the cache as on shutdown.
 // Returns an error if the server encounters issues during operation.
 func (s *Server) Run(ctx context.Context) error {
 
-	s.router.Use(s.loggingMiddleware, middleware.Recoverer)
+	var listeners []net.Listener
+	listen := func(addr string) (net.Listener, error) {
+		l, err := net.Listen("tcp", addr)
+		if err == nil {
+			listeners = append(listeners, l)
+		}
+		return l, err
+	}
+	fail := func(err error) error {
+		for _, l := range listeners {
+			l.Close()
+		}
+		s.close()
+		return err
+	}
 
-	s.router.Route("/api/lru", func(r chi.Router) {
-		r.Post("/", s.postKey)
-		r.Get("/{key}", s.getKey)
-		r.Get("/", s.getAllKeys)
-		r.Delete("/{key}", s.evictKey)
-		r.Delete("/", s.evictAllKeys)
-	})
+	httpListener, err := listen(s.cfg.HostPort)
+	if err != nil {
+		return fail(err)
+	}
+	var respListener, memcacheListener, grpcListener net.Listener
+	if s.cfg.RESPHostPort != "" {
+		if respListener, err = listen(s.cfg.RESPHostPort); err != nil {
+			return fail(err)
+		}
+	}
+	if s.cfg.MemcacheHostPort != "" {
+		if memcacheListener, err = listen(s.cfg.MemcacheHostPort); err != nil {
+			return fail(err)
+		}
+	}
+	if s.cfg.GRPCHostPort != "" {
+		if grpcListener, err = listen(s.cfg.GRPCHostPort); err != nil {
+			return fail(err)
+		}
+	}
 
 	server := http.Server{
 		Addr:    s.cfg.HostPort,
-		Handler: s.router,
+		Handler: s.Handler(),
 	}
 
 	go func() {
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		if err := server.Serve(httpListener); !errors.Is(err, http.ErrServerClosed) {
 			s.logger.Error("HTTP server error", slog.Any("error", err))
 			os.Exit(1)
 		}
 		s.logger.Info("Stopped serving new connections.")
 	}()
 
+	var tcpServers []*tcpsrv.Server
+	if respListener != nil {
+		server := resp.New(s.storage, s.logger)
+		s.serveTCP("RESP", respListener, server.Server)
+		tcpServers = append(tcpServers, server.Server)
+	}
+	if memcacheListener != nil {
+		server := memcache.New(s.storage, s.logger)
+		s.serveTCP("memcached", memcacheListener, server.Server)
+		tcpServers = append(tcpServers, server.Server)
+	}
+
+	var grpcServer *grpc.Server
+	if grpcListener != nil {
+		grpcServer = grpcsrv.New(s.storage, s.logger, s.cfg.DefaultTTL)
+		go func() {
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				s.logger.Error("gRPC server error", slog.Any("error", err))
+				os.Exit(1)
+			}
+			s.logger.Info("Stopped serving new gRPC connections.")
+		}()
+		s.logger.Info("Serving", slog.String("protocol", "gRPC"), slog.String("address", s.cfg.GRPCHostPort))
+	}
+
+	snapshotCtx, stopSnapshots := context.WithCancel(ctx)
+	defer stopSnapshots()
+	var snapshots sync.WaitGroup
+	if s.cfg.SnapshotPath != "" && s.cfg.SnapshotInterval > 0 {
+		snapshots.Add(1)
+		go func() {
+			defer snapshots.Done()
+			s.snapshotLoop(snapshotCtx)
+		}()
+	}
+
 	s.logger.Info("Running server")
 
 	sigChan := make(chan os.Signal, 1)
@@ -96,7 +358,58 @@ func (s *Server) Run(ctx context.Context) error {
 		s.logger.Error("HTTP shutdown error", slog.Any("error", err))
 		os.Exit(2)
 	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			s.logger.Error("gRPC shutdown error", slog.Any("error", shutdownCtx.Err()))
+			grpcServer.Stop()
+		}
+	}
+	for _, server := range tcpServers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.logger.Error("TCP shutdown error", slog.Any("error", err))
+		}
+	}
+	stopSnapshots()
+	snapshots.Wait()
+	s.close()
 	s.logger.Info("Graceful shutdown complete.")
 
 	return nil
-}
\ No newline at end of file
+}
+
+// close saves the snapshot, then closes the cache, which flushes the writes queued for the store,
+// and the append-only log.
+func (s *Server) close() {
+	if s.cfg.SnapshotPath != "" {
+		s.saveSnapshot()
+	}
+	if closer, ok := s.storage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			s.logger.Error("Cache shutdown error", slog.Any("error", err))
+		}
+	}
+	if s.aof != nil {
+		if err := s.aof.Close(); err != nil {
+			s.logger.Error("Append-only log shutdown error", slog.Any("error", err))
+		}
+	}
+}
+
+// serveTCP starts serving a protocol other than HTTP on l in the background.
+func (s *Server) serveTCP(protocol string, l net.Listener, server *tcpsrv.Server) {
+	go func() {
+		if err := server.Serve(l); !errors.Is(err, net.ErrClosed) {
+			s.logger.Error("TCP server error", slog.String("protocol", protocol), slog.Any("error", err))
+			os.Exit(1)
+		}
+		s.logger.Info("Stopped serving new connections.", slog.String("protocol", protocol))
+	}()
+	s.logger.Info("Serving", slog.String("protocol", protocol), slog.String("address", l.Addr().String()))
+}
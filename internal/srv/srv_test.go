@@ -1,21 +1,33 @@
 package srv
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"lru-cache/internal/cache"
 	"lru-cache/internal/models"
+	"lru-cache/internal/snapshot"
+	"lru-cache/pkg/errs"
 	"lru-cache/pkg/levelhandler"
+	"lru-cache/pkg/lru"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -136,6 +148,13 @@ func TestEvictKeyHandler(t *testing.T) {
 
 	assert.Equal(t, http.StatusNoContent, rec.Code)
 	assert.NotContains(t, mockCache.Store, "testKey")
+
+	// An empty cache answers like a missing key.
+	server.storage = cache.New(1)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 }
 
 func TestEvictAllKeysHandler(t *testing.T) {
@@ -160,3 +179,716 @@ func TestEvictAllKeysHandler(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, rec.Code)
 	assert.Empty(t, mockCache.Store)
 }
+
+func TestGetStatsHandler(t *testing.T) {
+	storage := cache.New(2)
+	storage.Put(context.Background(), "testKey1", "testValue1", time.Hour)
+	storage.Put(context.Background(), "testKey2", "testValue2", time.Hour)
+	storage.Put(context.Background(), "testKey3", "testValue3", time.Hour)
+	storage.Get(context.Background(), "testKey3")
+	storage.Get(context.Background(), "testKey1")
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	server := &Server{
+		storage: storage,
+		logger:  logger,
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/lru/_stats", server.getStats)
+	router.Get("/api/lru/{key}", server.getKey)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/_stats", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp models.StatsResponse
+	err := json.NewDecoder(rec.Body).Decode(&resp)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatsResponse{
+		Hits:      1,
+		Misses:    1,
+		HitRatio:  0.5,
+		Puts:      3,
+		Evictions: 1,
+		Size:      2,
+		Capacity:  2,
+	}, resp)
+
+	server.storage = cache.NewMockCache()
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+// TestMetricsHandler checks that requests and cache counters are exposed in the Prometheus text format.
+func TestMetricsHandler(t *testing.T) {
+	storage := cache.New(2)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	server := &Server{
+		storage: storage,
+		cfg: Config{
+			DefaultTTL: time.Minute,
+		},
+		logger:  logger,
+		metrics: newServerMetrics(storage),
+	}
+
+	router := chi.NewRouter()
+	router.Use(server.metricsMiddleware)
+	router.Get("/metrics", server.metrics.registry.ServeHTTP)
+	router.Post("/api/lru", server.postKey)
+	router.Get("/api/lru/{key}", server.getKey)
+
+	for _, key := range []string{"testKey1", "testKey2", "testKey3"} {
+		body := `{"key":"` + key + `","value":"testValue"}`
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/lru", strings.NewReader(body)))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/lru/testKey3", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/lru/testKey1", nil))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE http_requests_total counter",
+		`http_requests_total{route="/api/lru",method="POST",status="201"} 3`,
+		`http_requests_total{route="/api/lru/{key}",method="GET",status="200"} 1`,
+		`http_requests_total{route="/api/lru/{key}",method="GET",status="404"} 1`,
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_bucket{route="/api/lru",method="POST",status="201",le="+Inf"} 3`,
+		`http_request_duration_seconds_count{route="/api/lru",method="POST",status="201"} 3`,
+		"# TYPE lru_cache_size gauge",
+		"lru_cache_size 2",
+		"lru_cache_capacity 2",
+		"# TYPE lru_cache_hits_total counter",
+		"lru_cache_hits_total 1",
+		"lru_cache_misses_total 1",
+		"lru_cache_evictions_total 1",
+		"lru_cache_expirations_total 0",
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
+
+// TestGetStaleKeyHandler checks that a key served during its stale grace period is flagged as stale and refreshing.
+func TestGetStaleKeyHandler(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	storage := cache.New(2,
+		lru.WithLoader(func(ctx context.Context, key string) (any, time.Duration, error) {
+			<-release
+			return "freshValue", time.Hour, nil
+		}),
+		lru.WithStaleGrace[string, any](time.Hour),
+	)
+	storage.Put(context.Background(), "testKey", "testValue", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	server := &Server{
+		storage: storage,
+		logger:  logger,
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/lru/{key}", server.getKey)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru/testKey", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp models.GetResponse
+	err := resp.FromJSON(rec.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "testValue", resp.Value)
+	assert.True(t, resp.Stale)
+	assert.True(t, resp.Refreshing)
+}
+
+// TestStoreReload checks that a server backed by a store reloads stale keys from it during their grace period.
+func TestStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	server, err := New(Config{CacheSize: 10, CacheShards: 1, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR",
+		StoreDir: dir, StaleGrace: time.Hour})
+	assert.NoError(t, err)
+	defer server.storage.(io.Closer).Close()
+	handler := server.Handler()
+
+	ctx := context.Background()
+	assert.NoError(t, server.storage.Put(ctx, "a", "old", time.Millisecond))
+	store, err := cache.NewFileStore(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(ctx, "a", "new", time.Now().Add(time.Hour)))
+	time.Sleep(5 * time.Millisecond)
+
+	get := func() models.GetResponse {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru/a", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp models.GetResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+	resp := get()
+	assert.Equal(t, "old", resp.Value)
+	assert.True(t, resp.Stale)
+	assert.Eventually(t, func() bool {
+		resp := get()
+		return resp.Value == "new" && !resp.Stale && !resp.Refreshing
+	}, time.Second, 5*time.Millisecond)
+}
+
+// TestReplayBypassesStore checks that the append-only log is replayed into the cache without
+// writing the replayed entries to the backing store again.
+func TestReplayBypassesStore(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{CacheSize: 10, CacheShards: 1, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR",
+		StoreDir: dir, AOFPath: filepath.Join(t.TempDir(), "cache.aof"), AOFFsync: "always"}
+	server, err := New(cfg)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	assert.NoError(t, server.storage.Put(ctx, "a", "1", 0))
+	assert.NoError(t, server.storage.(io.Closer).Close())
+	assert.NoError(t, server.aof.Close())
+
+	assert.NoError(t, os.RemoveAll(dir))
+	assert.NoError(t, os.Mkdir(dir, 0o755))
+	server, err = New(cfg)
+	assert.NoError(t, err)
+	defer server.aof.Close()
+	defer server.storage.(io.Closer).Close()
+
+	value, _, err := server.storage.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+// TestAOFReplacesSnapshot checks that no snapshot is saved when the append-only log is enabled,
+// as it would never be restored.
+func TestAOFReplacesSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	server, err := New(Config{CacheSize: 10, CacheShards: 1, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR",
+		AOFPath: filepath.Join(dir, "cache.aof"), AOFFsync: "everysec", SnapshotPath: filepath.Join(dir, "cache.snapshot"), SnapshotInterval: time.Second})
+	assert.NoError(t, err)
+	assert.NoError(t, server.storage.Put(context.Background(), "a", "1", 0))
+	server.close()
+
+	_, err = os.Stat(filepath.Join(dir, "cache.snapshot"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// TestRunListenError checks that Run fails before serving anything when an address is in use,
+// closing the cache so that the writes queued for the store are flushed.
+func TestRunListenError(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer busy.Close()
+
+	dir := t.TempDir()
+	server, err := New(Config{CacheSize: 10, CacheShards: 1, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR",
+		HostPort: "127.0.0.1:0", RESPHostPort: "127.0.0.1:0", MemcacheHostPort: busy.Addr().String(),
+		StoreDir: dir, StoreWriteBehind: true, StoreFlushInterval: time.Hour})
+	assert.NoError(t, err)
+	ctx := context.Background()
+	assert.NoError(t, server.storage.Put(ctx, "a", "1", 0))
+
+	assert.Error(t, server.Run(ctx))
+	store, err := cache.NewFileStore(dir)
+	assert.NoError(t, err)
+	value, _, err := store.Load(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
+
+// TestSnapshotRestore checks that the server restores a snapshot in recency order without expired entries,
+// and starts empty from a corrupt one.
+func TestSnapshotRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	err := snapshot.Save(path, []lru.Entry[string, any]{
+		{Key: "testKey1", Value: "testValue1", ExpiresAt: expiresAt},
+		{Key: "testKey2", Value: "testValue2", ExpiresAt: time.Now().Add(-time.Second)},
+		{Key: "testKey3", Value: 3.0},
+	})
+	assert.NoError(t, err)
+
+	cfg := Config{CacheSize: 10, CacheShards: 1, EvictionPolicy: "lru", LogLevel: "ERROR", SnapshotPath: path}
+	server, err := New(cfg)
+	assert.NoError(t, err)
+
+	keys, values, err := server.storage.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testKey1", "testKey3"}, keys)
+	assert.Equal(t, []any{"testValue1", 3.0}, values)
+	_, got, err := server.storage.Get(context.Background(), "testKey1")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, expiresAt, got, time.Millisecond)
+
+	server.storage.Put(context.Background(), "testKey4", true, 0)
+	server.saveSnapshot()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[len(data)/2] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err = snapshot.Load(path)
+	assert.ErrorIs(t, err, errs.ErrCorruptSnapshot)
+	server, err = New(cfg)
+	assert.NoError(t, err)
+	_, _, err = server.storage.GetAll(context.Background())
+	assert.Equal(t, errs.ErrCacheIsEmpty, err)
+}
+
+// failingCache is a cache whose reads fail unexpectedly.
+type failingCache struct {
+	cache.ILRUCache
+}
+
+func (failingCache) Get(ctx context.Context, key string) (any, time.Time, error) {
+	return nil, time.Time{}, errors.New("broken")
+}
+
+// pointer escapes a JSON pointer token.
+func pointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// checkContract checks that the status code of rec is documented for the route and method,
+// and that its body matches the documented media type and schema.
+func checkContract(t *testing.T, doc map[string]any, compiler *jsonschema.Compiler, method, route string, rec *httptest.ResponseRecorder) {
+	status := strconv.Itoa(rec.Code)
+	location := "/paths/" + pointer(route) + "/" + strings.ToLower(method) + "/responses/" + status
+	operation, _ := doc["paths"].(map[string]any)[route].(map[string]any)[strings.ToLower(method)].(map[string]any)
+	if !assert.NotNil(t, operation, "%s %s is not documented", method, route) {
+		return
+	}
+	response, ok := operation["responses"].(map[string]any)[status].(map[string]any)
+	if !assert.True(t, ok, "%s %s does not document status %s", method, route, status) {
+		return
+	}
+	if ref, ok := response["$ref"].(string); ok {
+		location = strings.TrimPrefix(ref, "#")
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		response = doc["components"].(map[string]any)["responses"].(map[string]any)[name].(map[string]any)
+	}
+
+	content, _ := response["content"].(map[string]any)
+	if len(content) == 0 {
+		assert.Empty(t, rec.Body.String(), "%s %s %s has no documented body", method, route, status)
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	assert.NoError(t, err)
+	if !assert.Contains(t, content, mediaType, "%s %s %s", method, route, status) || mediaType != "application/json" {
+		return
+	}
+	schema, err := compiler.Compile("openapi.json#" + location + "/content/" + pointer(mediaType) + "/schema")
+	if !assert.NoError(t, err) {
+		return
+	}
+	body, err := jsonschema.UnmarshalJSON(rec.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, schema.Validate(body), "%s %s %s", method, route, status)
+}
+
+// TestOpenAPIContract checks that the handlers answer representative requests with status codes
+// and bodies described by the OpenAPI document they serve.
+func TestOpenAPIContract(t *testing.T) {
+	server, err := New(Config{CacheSize: 2, CacheShards: 1, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler := server.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var doc map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+	resource, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.Body.Bytes()))
+	assert.NoError(t, err)
+	compiler := jsonschema.NewCompiler()
+	assert.NoError(t, compiler.AddResource("openapi.json", resource))
+
+	tests := []struct {
+		method, path, route, body string
+		status                    int
+	}{
+		{http.MethodGet, "/api/lru", "/api/lru", "", http.StatusNoContent},
+		{http.MethodPost, "/api/lru", "/api/lru", `{"key":"a","value":1.5,"ttl_seconds":60}`, http.StatusCreated},
+		{http.MethodPost, "/api/lru", "/api/lru", `{"key":"b","value":{"x":[true,null]}}`, http.StatusCreated},
+		{http.MethodPost, "/api/lru", "/api/lru", `{"key":`, http.StatusBadRequest},
+		{http.MethodGet, "/api/lru/a", "/api/lru/{key}", "", http.StatusOK},
+		{http.MethodGet, "/api/lru/missing", "/api/lru/{key}", "", http.StatusNotFound},
+		{http.MethodGet, "/api/lru", "/api/lru", "", http.StatusOK},
+		{http.MethodGet, "/api/lru/_stats", "/api/lru/_stats", "", http.StatusOK},
+		{http.MethodGet, "/api/lru?limit=1&order=mru", "/api/lru", "", http.StatusOK},
+		{http.MethodGet, "/api/lru?match=x*", "/api/lru", "", http.StatusOK},
+		{http.MethodGet, "/api/lru?order=random", "/api/lru", "", http.StatusBadRequest},
+		{http.MethodGet, "/api/lru/_export", "/api/lru/_export", "", http.StatusOK},
+		{http.MethodPost, "/api/lru/_import", "/api/lru/_import", `{"key":"e","value":1,"rank":0}` + "\n", http.StatusOK},
+		{http.MethodPost, "/api/lru/_import", "/api/lru/_import", `{"key":`, http.StatusBadRequest},
+		{http.MethodGet, "/api/lru?cursor=@", "/api/lru", "", http.StatusBadRequest},
+		{http.MethodDelete, "/api/lru/a", "/api/lru/{key}", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/lru/a", "/api/lru/{key}", "", http.StatusNotFound},
+		{http.MethodPost, "/api/lru/_mset", "/api/lru/_mset", `{"entries":[{"key":"c","value":"x","ttl_seconds":5},{"key":"d","value":null}]}`, http.StatusOK},
+		{http.MethodPost, "/api/lru/_mset", "/api/lru/_mset", `{"entries":{}}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/lru/_mget", "/api/lru/_mget", `{"keys":["c","missing","d"]}`, http.StatusOK},
+		{http.MethodPost, "/api/lru/_mget", "/api/lru/_mget", `[]`, http.StatusBadRequest},
+		{http.MethodPost, "/api/lru/_mdel", "/api/lru/_mdel", `{"keys":["c","missing"]}`, http.StatusOK},
+		{http.MethodPost, "/api/lru/d/_incr", "/api/lru/{key}/_incr", "", http.StatusConflict},
+		{http.MethodPost, "/api/lru/n/_incr", "/api/lru/{key}/_incr", `{"delta":2,"ttl_seconds":60}`, http.StatusOK},
+		{http.MethodPost, "/api/lru/n/_incr", "/api/lru/{key}/_incr", "", http.StatusOK},
+		{http.MethodPost, "/api/lru/n/_incr", "/api/lru/{key}/_incr", `{"delta":"x"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/lru", "/api/lru", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/lru", "/api/lru", "", http.StatusNoContent},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		assert.Equal(t, test.status, rec.Code, "%s %s", test.method, test.path)
+		checkContract(t, doc, compiler, test.method, test.route, rec)
+	}
+
+	conditional := []struct {
+		method, path, route, header, value, body string
+		status                                   int
+	}{
+		{http.MethodPost, "/api/lru", "/api/lru", "", "", `{"key":"v","value":1,"mode":"if_absent"}`, http.StatusCreated},
+		{http.MethodPost, "/api/lru", "/api/lru", "", "", `{"key":"v","value":1,"mode":"if_absent"}`, http.StatusConflict},
+		{http.MethodPost, "/api/lru", "/api/lru", "", "", `{"key":"v","value":1,"mode":"sometimes"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/lru", "/api/lru", "If-None-Match", "*", `{"key":"v","value":1}`, http.StatusPreconditionFailed},
+		{http.MethodGet, "/api/lru/v", "/api/lru/{key}", "If-None-Match", "*", "", http.StatusNotModified},
+	}
+	for _, test := range conditional {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, test.status, rec.Code, "%s %s %s", test.method, test.path, test.body)
+		checkContract(t, doc, compiler, test.method, test.route, rec)
+	}
+
+	server.storage = failingCache{server.storage}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru/a", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	checkContract(t, doc, compiler, http.MethodGet, "/api/lru/{key}", rec)
+
+	server.storage = cache.NewMockCache()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru/_stats", nil))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	checkContract(t, doc, compiler, http.MethodGet, "/api/lru/_stats", rec)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru?limit=10", nil))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	checkContract(t, doc, compiler, http.MethodGet, "/api/lru", rec)
+}
+
+// TestBatchHandlers checks that the batch handlers return a result for every key in the order of the request,
+// and that mset reports the values that could not be stored.
+func TestBatchHandlers(t *testing.T) {
+	server, err := New(Config{CacheSize: 10, CacheShards: 4, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler := server.Handler()
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("/api/lru/_mset", `{"entries":[{"key":"a","value":1,"ttl_seconds":3600},{"key":"b","value":"two"},{"key":"a","value":3}]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results":[{"key":"a","stored":true},{"key":"b","stored":true},{"key":"a","stored":true}]}`, rec.Body.String())
+
+	rec = post("/api/lru/_mget", `{"keys":["b","missing","a"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got models.BatchResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Len(t, got.Results, 3)
+	assert.Equal(t, models.BatchResult{Key: "missing"}, got.Results[1])
+	assert.Equal(t, "b", got.Results[0].Key)
+	assert.Equal(t, "two", got.Results[0].Value)
+	assert.Equal(t, 3.0, got.Results[2].Value)
+	assert.InDelta(t, time.Now().Add(time.Minute).Unix(), got.Results[2].ExpiresAt, 2)
+
+	rec = post("/api/lru/_mdel", `{"keys":["a","missing","a"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results":[{"key":"a","found":true},{"key":"missing","found":false},{"key":"a","found":false}]}`, rec.Body.String())
+
+	keys, _ := json.Marshal(map[string]any{"keys": make([]string, maxBatch+1)})
+	rec = post("/api/lru/_mget", string(keys))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// A value larger than the whole cache is not stored.
+	server, err = New(Config{CacheMaxBytes: 1024, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler = server.Handler()
+	rec = post("/api/lru/_mset", `{"entries":[{"key":"big","value":"`+strings.Repeat("x", 2048)+`"},{"key":"small","value":1}]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results":[{"key":"big","stored":false},{"key":"small","stored":true}]}`, rec.Body.String())
+}
+
+// TestScanKeysHandler checks that listing parameters switch get all keys to pages that cover the whole cache
+// once, in order and filtered, while the response without parameters keeps its shape.
+func TestScanKeysHandler(t *testing.T) {
+	server, err := New(Config{CacheSize: 100, CacheShards: 3, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler := server.Handler()
+	for i := range 30 {
+		rec := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"key":"user:%02d","value":%d}`, i, i)
+		if i%3 == 0 {
+			body = fmt.Sprintf(`{"key":"item:%02d","value":%d}`, i, i)
+		}
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/lru", strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru"+query, nil))
+		return rec
+	}
+
+	var all models.GetAllResponse
+	assert.NoError(t, json.Unmarshal(get("").Body.Bytes(), &all))
+	assert.Len(t, all.Keys, 30)
+
+	var keys []string
+	cursor := ""
+	for pages := 0; pages < 20; pages++ {
+		rec := get("?match=user:*&limit=7&cursor=" + cursor)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var page models.ScanResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.LessOrEqual(t, len(page.Entries), 7)
+		for _, e := range page.Entries {
+			keys = append(keys, e.Key)
+			assert.Greater(t, e.ExpiresAt, 0)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Empty(t, cursor)
+	assert.Len(t, keys, 20)
+	for _, key := range keys {
+		assert.True(t, strings.HasPrefix(key, "user:"), key)
+	}
+
+	var page models.ScanResponse
+	rec := get("?order=mru&limit=1000")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Len(t, page.Entries, 30)
+	assert.Empty(t, page.NextCursor)
+
+	assert.Equal(t, http.StatusBadRequest, get("?limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?limit=1001").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?cursor=bm9wZQ").Code)
+}
+
+// TestExportImport checks that an export loaded into another server rebuilds the same entries
+// with their expiration times and recency, leaving out the expired ones.
+func TestExportImport(t *testing.T) {
+	newServer := func(shards int) http.Handler {
+		server, err := New(Config{CacheSize: 3000, CacheShards: shards, EvictionPolicy: "lru", DefaultTTL: time.Hour, LogLevel: "ERROR"})
+		assert.NoError(t, err)
+		return server.Handler()
+	}
+	export := func(h http.Handler) (keys []string, body string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru/_export", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		for i, line := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+			var e models.ExportEntry
+			assert.NoError(t, json.Unmarshal([]byte(line), &e))
+			assert.Equal(t, i, e.Rank)
+			keys = append(keys, e.Key)
+		}
+		return keys, rec.Body.String()
+	}
+	from, to := newServer(4), newServer(1)
+	for i := range 2500 {
+		rec := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"key":"k%d","value":{"n":%d}}`, i, i)
+		from.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/lru", strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	keys, body := export(from)
+	assert.Len(t, keys, 2500)
+	body += `{"key":"expired","value":1,"expires_at":1,"rank":2500}` + "\n" + `{"key":"forever","value":"x","rank":2501}`
+	rec := httptest.NewRecorder()
+	to.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/lru/_import", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"imported":2501,"expired":1}`, rec.Body.String())
+
+	imported, _ := export(to)
+	assert.Equal(t, append(keys, "forever"), imported)
+
+	rec = httptest.NewRecorder()
+	to.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru/k42", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got models.GetResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, map[string]any{"n": 42.0}, got.Value)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), got.ExpiresAt, 2)
+
+	rec = httptest.NewRecorder()
+	to.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lru/forever", nil))
+	got = models.GetResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Zero(t, got.ExpiresAt)
+}
+
+// TestConditionalWrites checks the write modes of post key, set in the body or with precondition headers,
+// and the ETag of get key.
+func TestConditionalWrites(t *testing.T) {
+	server, err := New(Config{CacheSize: 10, CacheShards: 2, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler := server.Handler()
+	post := func(body string, header ...string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", strings.NewReader(body))
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(key string, header ...string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/lru/"+key, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusConflict, post(`{"key":"a","value":1,"mode":"if_present"}`).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, post(`{"key":"a","value":1}`, "If-Match", "*").Code)
+	rec := post(`{"key":"a","value":1,"mode":"if_absent"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	created := rec.Header().Get("ETag")
+	assert.NotEmpty(t, created)
+
+	rec = get("a")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, created, rec.Header().Get("ETag"))
+	var got models.GetResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, created, etag(got.Version))
+	assert.Equal(t, http.StatusNotModified, get("a", "If-None-Match", `"1", W/`+created).Code)
+
+	// Two writers racing from the same version: only the first one wins.
+	rec = post(`{"key":"a","value":2}`, "If-Match", created)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	updated := rec.Header().Get("ETag")
+	assert.NotEqual(t, created, updated)
+	assert.Equal(t, http.StatusPreconditionFailed, post(`{"key":"a","value":3}`, "If-Match", created).Code)
+	version, _ := parseETag(created)
+	assert.Equal(t, http.StatusConflict, post(fmt.Sprintf(`{"key":"a","value":3,"if_version":%d}`, version)).Code)
+	version, _ = parseETag(updated)
+	assert.Equal(t, http.StatusCreated, post(fmt.Sprintf(`{"key":"a","value":3,"mode":"if_version","if_version":%d}`, version)).Code)
+	assert.NoError(t, json.Unmarshal(get("a").Body.Bytes(), &got))
+	assert.Equal(t, 3.0, got.Value)
+
+	assert.Equal(t, http.StatusPreconditionFailed, post(`{"key":"a","value":4}`, "If-None-Match", "*").Code)
+	assert.Equal(t, http.StatusCreated, post(`{"key":"a","value":4}`, "If-Match", "*").Code)
+	assert.Equal(t, http.StatusConflict, post(`{"key":"missing","value":1,"if_version":1}`).Code)
+
+	for _, invalid := range []struct{ body, header, value string }{
+		{`{"key":"a","value":1,"mode":"if_version"}`, "", ""},
+		{`{"key":"a","value":1,"mode":"if_absent","if_version":1}`, "", ""},
+		{`{"key":"a","value":1,"mode":"if_absent"}`, "If-Match", "*"},
+		{`{"key":"a","value":1}`, "If-Match", "W/" + updated},
+		{`{"key":"a","value":1}`, "If-None-Match", updated},
+	} {
+		assert.Equal(t, http.StatusBadRequest, post(invalid.body, invalid.header, invalid.value).Code, invalid)
+	}
+
+	server.storage = cache.NewMockCache()
+	assert.Equal(t, http.StatusNotImplemented, post(`{"key":"a","value":1,"if_version":1}`).Code)
+	rec = post(`{"key":"a","value":1,"mode":"if_absent"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+}
+
+// TestIncrHandler checks that increments create counters with a TTL, keep it afterwards,
+// switch to floats on a fractional delta and reject values that are not numbers.
+func TestIncrHandler(t *testing.T) {
+	server, err := New(Config{CacheSize: 10, CacheShards: 2, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler := server.Handler()
+	incr := func(key, body string) (*httptest.ResponseRecorder, models.IncrResponse) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/lru/"+key+"/_incr", strings.NewReader(body)))
+		var got models.IncrResponse
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		}
+		return rec, got
+	}
+
+	rec, got := incr("views%2F1", `{"delta":5,"ttl_seconds":3600}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "views/1", got.Key)
+	assert.Equal(t, 5.0, got.Value)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), got.ExpiresAt, 1)
+	expiresAt := got.ExpiresAt
+
+	_, got = incr("views%2F1", `{"delta":-2,"ttl_seconds":10}`)
+	assert.Equal(t, 3.0, got.Value)
+	assert.Equal(t, expiresAt, got.ExpiresAt)
+	_, got = incr("views%2F1", "")
+	assert.Equal(t, 4.0, got.Value)
+
+	_, got = incr("ratio", `{"delta":0.25}`)
+	assert.Equal(t, 0.25, got.Value)
+	assert.InDelta(t, time.Now().Add(time.Minute).Unix(), got.ExpiresAt, 1)
+	rec, _ = incr("ratio", `{"delta":1}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "Value is not an integer\n", rec.Body.String())
+	_, got = incr("ratio", `{"delta":1.0}`)
+	assert.Equal(t, 1.25, got.Value)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/lru", strings.NewReader(`{"key":"s","value":"x"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	rec, _ = incr("s", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "Value is not a number\n", rec.Body.String())
+	rec, _ = incr("s", `{"delta":true}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// A new counter the admission filter keeps out of a full cache is not reported as incremented.
+	server, err = New(Config{CacheSize: 2, CacheShards: 1, CacheAdmission: true, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler = server.Handler()
+	for _, key := range []string{"a", "b"} {
+		incr(key, "")
+		for range 5 {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/lru/"+key, nil))
+		}
+	}
+	for range 2 {
+		rec, _ = incr("limit", "")
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+	}
+}
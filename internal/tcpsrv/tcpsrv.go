@@ -0,0 +1,106 @@
+// Package tcpsrv accepts TCP connections for the line protocols the cache is served over,
+// keeping track of them so that they can be shut down gracefully.
+package tcpsrv
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Server serves every accepted connection in its own goroutine.
+type Server struct {
+	serve func(conn net.Conn)
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closing  atomic.Bool
+	wg       sync.WaitGroup
+}
+
+// New creates a Server handing every connection to serve, which must return once a read
+// from the connection fails. The connection is closed when serve returns.
+func New(serve func(conn net.Conn)) *Server {
+	return &Server{serve: serve, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve accepts connections on l and serves them until Shutdown is called.
+// It always returns a non-nil error, net.ErrClosed after Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closing.Load() {
+		s.mu.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		if s.closing.Load() {
+			s.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.serve(conn)
+		}()
+	}
+}
+
+// Closing tells whether Shutdown has been called. Connections should stop after answering their current request.
+func (s *Server) Closing() bool {
+	return s.closing.Load()
+}
+
+// Shutdown stops accepting connections and waits for the open ones to finish their current request
+// until ctx is done, after which they are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing.Store(true)
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		// Wake up connections waiting for a request, the others stop after replying.
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,304 @@
+// Package client provides a client of the HTTP API of the cache server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lru-cache/pkg/errs"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client is a client of the HTTP API of the cache server. It has the methods of the cache interface
+// of the server, so that it can be used in place of an in-process cache, and is safe for concurrent use.
+//
+// Requests failing with a 5xx status or a network error are retried with exponential backoff,
+// as long as the context of the call allows it.
+type Client struct {
+	baseURL    string
+	http       *http.Client
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	timeout    time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client sending the requests, a pooling client is used by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.http = c
+	}
+}
+
+// WithRetries sets how many times a failed request is retried, 3 by default, waiting for a random time
+// up to backoff before the first retry, doubled for every next one up to maxBackoff.
+func WithRetries(retries int, backoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries, c.backoff, c.maxBackoff = retries, backoff, maxBackoff
+	}
+}
+
+// WithTimeout bounds every call whose context has no deadline, retries included. Unbounded by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// New creates a Client of the server at baseURL, such as http://localhost:8080.
+func New(baseURL string, opts ...Option) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 64
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/") + "/api/lru/",
+		http:       &http.Client{Transport: transport},
+		retries:    3,
+		backoff:    50 * time.Millisecond,
+		maxBackoff: time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Close closes the idle pooled connections.
+func (c *Client) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// StatusError is returned for responses with an unexpected status code.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("client: unexpected status %d: %s", e.Code, e.Body)
+}
+
+// putRequest, getResponse and getAllResponse are the bodies of the API the client sends and reads.
+// They are declared here so that the package depends on the wire format only, not on the server.
+type putRequest struct {
+	Key        string `json:"key"`
+	Value      any    `json:"value"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+type getResponse struct {
+	Value     any `json:"value"`
+	ExpiresAt int `json:"expires_at"`
+}
+
+type getAllResponse struct {
+	Keys   []string `json:"keys"`
+	Values []any    `json:"values"`
+}
+
+// Put stores data in the cache with a specified TTL, rounded up to whole seconds.
+// Unlike with the in-process cache, a zero TTL means the default TTL of the server.
+func (c *Client) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	body, err := json.Marshal(putRequest{Key: key, Value: value, TTLSeconds: int(math.Ceil(ttl.Seconds()))})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return statusError(resp)
+	}
+	return nil
+}
+
+// Get retrieves data from the cache by key.
+// Returns the value, expiration time, and errs.ErrNotFound if the key is not found or has expired,
+// or is empty as the API cannot address it.
+// The expiration time is precise to the second.
+func (c *Client) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if key == "" {
+		return nil, time.Time{}, errs.ErrNotFound
+	}
+	resp, err := c.do(ctx, http.MethodGet, url.PathEscape(key), nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, time.Time{}, errs.ErrNotFound
+	default:
+		return nil, time.Time{}, statusError(resp)
+	}
+	var data getResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, time.Time{}, err
+	}
+	if data.ExpiresAt > 0 {
+		expiresAt = time.Unix(int64(data.ExpiresAt), 0)
+	}
+	return data.Value, expiresAt, nil
+}
+
+// GetAll retrieves all entries from the cache as two slices: a slice of keys and a slice of values.
+// Returns errs.ErrCacheIsEmpty if the cache is empty.
+func (c *Client) GetAll(ctx context.Context) (keys []string, values []interface{}, err error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil, errs.ErrCacheIsEmpty
+	default:
+		return nil, nil, statusError(resp)
+	}
+	var data getAllResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, nil, err
+	}
+	return data.Keys, data.Values, nil
+}
+
+// Evict removes data by key from the cache. The server does not send the removed value back,
+// so it is always nil. Returns errs.ErrNotFound if the key is not found or has expired,
+// which may also happen when a retry follows an eviction whose response was lost.
+func (c *Client) Evict(ctx context.Context, key string) (value interface{}, err error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if key == "" {
+		return nil, errs.ErrNotFound
+	}
+	resp, err := c.do(ctx, http.MethodDelete, url.PathEscape(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusNotFound:
+		return nil, errs.ErrNotFound
+	}
+	return nil, statusError(resp)
+}
+
+// EvictAll invalidates the entire cache.
+func (c *Client) EvictAll(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodDelete, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return statusError(resp)
+	}
+	return nil
+}
+
+// withTimeout bounds ctx by the timeout of the client if it has no deadline.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// do sends a request for the path relative to the API, retrying on network errors and 5xx statuses.
+// The body of the returned response must be closed.
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := c.http.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+		if attempt == c.retries || !retryable(ctx, err) {
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := c.wait(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable tells whether a request that failed with err, nil for a 5xx status, may be retried.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	return err == nil || errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// wait sleeps before the retry following the given attempt, with full jitter.
+func (c *Client) wait(ctx context.Context, attempt int) error {
+	backoff := min(c.backoff<<attempt, c.maxBackoff)
+	if backoff <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(rand.N(backoff) + 1)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// statusError reads the response into a StatusError.
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+}
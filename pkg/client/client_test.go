@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lru-cache/internal/cache"
+	"lru-cache/internal/srv"
+	"lru-cache/pkg/errs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ cache.ILRUCache = (*Client)(nil)
+
+// server starts a real server wrapped in middleware and returns its URL.
+func server(t *testing.T, middleware func(http.Handler) http.Handler) string {
+	s, err := srv.New(srv.Config{CacheSize: 10, CacheShards: 1, EvictionPolicy: "lru", DefaultTTL: time.Minute, LogLevel: "ERROR"})
+	assert.NoError(t, err)
+	handler := s.Handler()
+	if middleware != nil {
+		handler = middleware(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+// TestClient checks every method against a real server, including the mapping of statuses to errors.
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := New(server(t, nil))
+	defer c.Close()
+
+	_, _, err := c.GetAll(ctx)
+	assert.ErrorIs(t, err, errs.ErrCacheIsEmpty)
+	_, _, err = c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+	_, err = c.Evict(ctx, "missing")
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+
+	assert.NoError(t, c.Put(ctx, "a b/c", "1", time.Hour))
+	assert.NoError(t, c.Put(ctx, "n", 2.5, 0))
+	assert.NoError(t, c.Put(ctx, "obj", map[string]any{"x": true}, 1500*time.Millisecond))
+
+	value, expiresAt, err := c.Get(ctx, "a b/c")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)
+	_, expiresAt, err = c.Get(ctx, "n")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+	_, expiresAt, err = c.Get(ctx, "obj")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), expiresAt, 2*time.Second)
+
+	keys, values, err := c.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a b/c", "n", "obj"}, keys)
+	assert.Equal(t, []any{"1", 2.5, map[string]any{"x": true}}, values)
+
+	_, err = c.Evict(ctx, "a b/c")
+	assert.NoError(t, err)
+	_, _, err = c.Get(ctx, "a b/c")
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+
+	assert.NoError(t, c.EvictAll(ctx))
+	_, _, err = c.GetAll(ctx)
+	assert.ErrorIs(t, err, errs.ErrCacheIsEmpty)
+}
+
+// TestRetries checks that 5xx responses are retried until the retries run out.
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	var failures, requests atomic.Int32
+	url := server(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if failures.Add(-1) >= 0 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := New(url, WithRetries(2, time.Millisecond, 10*time.Millisecond))
+
+	failures.Store(2)
+	assert.NoError(t, c.Put(ctx, "a", "1", 0))
+	assert.Equal(t, int32(3), requests.Load())
+
+	failures.Store(3)
+	_, _, err := c.Get(ctx, "a")
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.Code)
+	assert.Equal(t, int32(6), requests.Load())
+}
+
+// TestDeadline checks that calls give up when their context is done, including while waiting for a retry.
+func TestDeadline(t *testing.T) {
+	url := server(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				time.Sleep(50 * time.Millisecond)
+			}
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		})
+	})
+
+	c := New(url, WithTimeout(10*time.Millisecond))
+	start := time.Now()
+	_, _, err := c.Get(context.Background(), "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 40*time.Millisecond)
+
+	c = New(url, WithRetries(10, time.Second, time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Evict(ctx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -12,4 +12,34 @@ var (
 	//ErrIncorrectLogLevel is used when it's impossible to parse log level
 	//from a flag or env.
 	ErrIncorrectLogLevel = errors.New("unable to parse log level")
+	//ErrUnknownPolicy is used when it's impossible to parse eviction policy
+	//from a flag or env.
+	ErrUnknownPolicy     = errors.New("unknown eviction policy")
+	//ErrCorruptSnapshot is used when a snapshot file is truncated
+	//or doesn't match its checksum.
+	ErrCorruptSnapshot   = errors.New("corrupt snapshot")
+	//ErrSnapshotVersion is used when a snapshot file was written
+	//in a format version this build can't read.
+	ErrSnapshotVersion   = errors.New("unsupported snapshot version")
+	//ErrUnknownFsync is used when it's impossible to parse the fsync policy
+	//of the append-only log from a flag or env.
+	ErrUnknownFsync      = errors.New("unknown fsync policy")
+	//ErrInvalidCursor is used when a listing cursor is malformed
+	//or wasn't returned by a previous page.
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	//ErrNotNumeric is used when a value can't be incremented
+	//because it isn't a number.
+	ErrNotNumeric        = errors.New("value is not a number")
+	//ErrNotInteger is used when a value can't be incremented
+	//by an integer because it has a fractional part.
+	ErrNotInteger        = errors.New("value is not an integer")
+	//ErrOverflow is used when an increment would overflow
+	//the value.
+	ErrOverflow          = errors.New("increment would overflow")
+	//ErrNotBackable is used when a cache can't be put in front
+	//of a backing store.
+	ErrNotBackable       = errors.New("cache can't be backed by a store")
+	//ErrNotStored is used when a new value wasn't stored because it
+	//doesn't fit in the cache or was rejected by the admission filter.
+	ErrNotStored         = errors.New("value was not stored")
 )
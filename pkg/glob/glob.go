@@ -0,0 +1,90 @@
+// Package glob matches strings against the glob-style patterns of Redis' KEYS command.
+package glob
+
+// Match reports whether name matches pattern, where '*' matches any sequence of characters,
+// '?' matches any single character, '[abc]', '[a-z]' and '[^a]' match a character from or outside a set,
+// and '\' escapes the character that follows it. Unlike path.Match, '*' also matches '/'.
+// A malformed pattern matches literally where it is malformed.
+func Match(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if Match(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+			name = name[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(name) == 0 {
+				return false
+			}
+			matched, rest, ok := matchClass(pattern[1:], name[0])
+			if !ok {
+				// An unterminated class is a literal '['.
+				if name[0] != '[' {
+					return false
+				}
+				pattern = pattern[1:]
+			} else {
+				if !matched {
+					return false
+				}
+				pattern = rest
+			}
+			name = name[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(name) == 0 || name[0] != pattern[0] {
+				return false
+			}
+			name = name[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(name) == 0
+}
+
+// matchClass matches c against the character class at the start of pattern, right after its '['.
+// Returns the rest of the pattern after the closing ']', or false if there is none.
+func matchClass(pattern string, c byte) (matched bool, rest string, ok bool) {
+	negate := len(pattern) > 0 && (pattern[0] == '^' || pattern[0] == '!')
+	if negate {
+		pattern = pattern[1:]
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == ']' && i > 0:
+			return matched != negate, pattern[i+1:], true
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			i++
+			matched = matched || pattern[i] == c
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (lo <= c && c <= hi)
+			i += 2
+		default:
+			matched = matched || pattern[i] == c
+		}
+	}
+	return false, "", false
+}
@@ -0,0 +1,116 @@
+package lru
+
+// sketch is a count-min sketch of 4-bit saturating counters estimating how often keys were seen.
+// Counters are halved once the number of increments reaches ten times the capacity of the cache,
+// so the estimate follows the recent popularity of keys rather than their whole history.
+type sketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	capacity  int
+	additions int
+	limit     int
+}
+
+func newSketch(capacity int) *sketch {
+	width := 64
+	for width < 4*capacity {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), capacity: capacity, limit: 10 * max(capacity, 16)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index spreads a key hash over the rows with double hashing.
+func (s *sketch) index(hash uint64, row int) uint64 {
+	return (hash + uint64(row)*(hash>>32|1)) & s.mask
+}
+
+func (s *sketch) increment(hash uint64) {
+	added := false
+	for i := range s.rows {
+		if idx := s.index(hash, i); s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	if s.additions++; s.additions >= s.limit {
+		s.age()
+	}
+}
+
+func (s *sketch) estimate(hash uint64) uint8 {
+	est := uint8(15)
+	for i := range s.rows {
+		est = min(est, s.rows[i][s.index(hash, i)])
+	}
+	return est
+}
+
+func (s *sketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// admission is the TinyLFU admission filter: every read and write of a key is recorded in a sketch,
+// and a new key only displaces the victim of the eviction policy if it was seen more often.
+type admission[K comparable] struct {
+	hash   func(K) uint64
+	sketch *sketch
+}
+
+func (a *admission[K]) record(key K) {
+	a.sketch.increment(a.hash(key))
+}
+
+func (a *admission[K]) admit(candidate, victim K) bool {
+	return a.sketch.estimate(a.hash(candidate)) > a.sketch.estimate(a.hash(victim))
+}
+
+// fit grows the sketch when the cache holds more entries than it was sized for, which happens when
+// the number of entries of a cache bounded by weight was underestimated. The counts start over,
+// as they cannot be spread over the new counters without the keys.
+func (a *admission[K]) fit(entries int) {
+	if entries > a.sketch.capacity {
+		a.sketch = newSketch(2 * entries)
+	}
+}
+
+// Sizing the sketch of a cache bounded by weight only, whose number of entries is not known in advance.
+const (
+	// entryWeight is the weight assumed for an entry, about the size in bytes of a small entry of the HTTP cache.
+	entryWeight = 64
+	// maxExpectedEntries caps the initial estimate, so that a large weight limit doesn't allocate a large sketch
+	// up front. The sketch grows if the cache holds more entries.
+	maxExpectedEntries = 1 << 16
+)
+
+// expectedEntries returns the number of entries the cache is expected to hold, its capacity if it has one
+// and otherwise an estimate from its weight limit.
+func (c *Cache[K, V]) expectedEntries() int {
+	if c.capacity > 0 || c.maxWeight <= 0 {
+		return c.capacity
+	}
+	return int(min(c.maxWeight/entryWeight, maxExpectedEntries))
+}
+
+// WithAdmission puts a TinyLFU admission filter in front of the eviction policy, as in Caffeine's W-TinyLFU,
+// so that keys requested only once cannot push out more valuable entries when the cache is full.
+// hash must spread keys uniformly over 64 bits. Admitted and rejected keys are counted in Stats.
+// When a new key needs several entries evicted, as with WithMaxWeight, it is only compared with the first victim.
+// The sketch is sized for the capacity of the cache or, if it is only bounded by weight, for an estimate
+// of its number of entries, and grows if the cache holds more.
+func WithAdmission[K comparable, V any](hash func(K) uint64) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.admission = &admission[K]{hash: hash}
+	}
+}
@@ -0,0 +1,91 @@
+package lru
+
+// arc implements the Adaptive Replacement Cache. Entries seen once live in the recent list t1,
+// entries seen at least twice in the frequent list t2, and the keys recently evicted from each
+// are remembered in the ghost lists b1 and b2. A hit in b1 means t1 is too small and grows its
+// target size p, a hit in b2 shrinks it, and the victim is taken from whichever list exceeds its target.
+type arc[K comparable, V any] struct {
+	capacity int
+	p        int
+	t1, t2   *queue[K, V]
+	b1, b2   *ghosts[K]
+}
+
+func newARC[K comparable, V any](capacity int) *arc[K, V] {
+	return &arc[K, V]{
+		capacity: capacity,
+		t1:       newQueue[K, V](),
+		t2:       newQueue[K, V](),
+		b1:       newGhosts[K](),
+		b2:       newGhosts[K](),
+	}
+}
+
+// size is the number of entries the lists are balanced for. When the cache is not bounded
+// by entry count, it is the number of entries it currently holds.
+func (p *arc[K, V]) size() int {
+	return max(p.capacity, p.t1.len+p.t2.len, 1)
+}
+
+func (p *arc[K, V]) admit(nd *entry[K, V]) {
+	switch {
+	case p.b1.contains(nd.key):
+		p.p = min(p.size(), p.p+max(p.b2.len()/p.b1.len(), 1))
+		p.b1.remove(nd.key)
+	case p.b2.contains(nd.key):
+		p.p = max(0, p.p-max(p.b1.len()/p.b2.len(), 1))
+		p.b2.remove(nd.key)
+	default:
+		nd.queue = queueIn
+		p.t1.pushBack(nd)
+		return
+	}
+	nd.queue = queueMain
+	p.t2.pushBack(nd)
+}
+
+func (p *arc[K, V]) access(nd *entry[K, V]) {
+	if nd.queue == queueIn {
+		p.t1.remove(nd)
+		nd.queue = queueMain
+		p.t2.pushBack(nd)
+		return
+	}
+	p.t2.moveToBack(nd)
+}
+
+func (p *arc[K, V]) victim(candidate K) *entry[K, V] {
+	if p.t1.len > 0 && (p.t1.len > p.p || (p.b2.contains(candidate) && p.t1.len == p.p) || p.t2.len == 0) {
+		return p.t1.front()
+	}
+	return p.t2.front()
+}
+
+func (p *arc[K, V]) remove(nd *entry[K, V], evicted bool) {
+	if nd.queue == queueIn {
+		p.t1.remove(nd)
+		if evicted {
+			p.b1.push(nd.key)
+		}
+	} else {
+		p.t2.remove(nd)
+		if evicted {
+			p.b2.push(nd.key)
+		}
+	}
+	c := p.size()
+	for p.b1.len() > 0 && p.t1.len+p.b1.len() > c {
+		p.b1.pop()
+	}
+	for p.b2.len() > 0 && p.t1.len+p.t2.len+p.b1.len()+p.b2.len() > 2*c {
+		p.b2.pop()
+	}
+}
+
+func (p *arc[K, V]) reset() {
+	p.p = 0
+	p.t1.reset()
+	p.t2.reset()
+	p.b1.reset()
+	p.b2.reset()
+}
@@ -0,0 +1,114 @@
+package lru
+
+import "time"
+
+// GetMany retrieves the entries stored under keys like Get, taking the lock once for the whole batch.
+// The results are at the positions of their keys, ok[i] being false if keys[i] is not found or has expired.
+func (c *Cache[K, V]) GetMany(keys []K) (items []Item[V], ok []bool) {
+	items, ok = make([]Item[V], len(keys)), make([]bool, len(keys))
+	c.mu.Lock()
+	defer c.unlock()
+
+	for i, key := range keys {
+		items[i], ok[i] = c.lookup(key)
+	}
+	return items, ok
+}
+
+// PutMany stores values[i] under keys[i] with ttls[i] like Put, in order, taking the lock once for the whole batch.
+// The three slices must have the same length. stored[i] is false if values[i] was not admitted, because it
+// is larger than the whole cache or was rejected by WithAdmission; a stored value may still be evicted
+// by a later put of the batch.
+func (c *Cache[K, V]) PutMany(keys []K, values []V, ttls []time.Duration) (stored []bool) {
+	stored = make([]bool, len(keys))
+	c.mu.Lock()
+	defer c.unlock()
+
+	for i, key := range keys {
+		stored[i] = c.put(key, values[i], ttls[i])
+	}
+	return stored
+}
+
+// EvictMany removes the entries stored under keys like Evict, taking the lock once for the whole batch.
+// The removed values are at the positions of their keys, ok[i] being false if keys[i] is not found or has expired.
+func (c *Cache[K, V]) EvictMany(keys []K) (values []V, ok []bool) {
+	values, ok = make([]V, len(keys)), make([]bool, len(keys))
+	c.mu.Lock()
+	defer c.unlock()
+
+	now := time.Now()
+	for i, key := range keys {
+		delete(c.failures, key)
+		nd, found := c.data[key]
+		if !found {
+			continue
+		}
+		if c.journal != nil {
+			c.journal.Evict(key)
+		}
+		if nd.expired(now) {
+			c.drop(nd, ReasonExpired)
+			continue
+		}
+		c.drop(nd, ReasonExplicit)
+		values[i], ok[i] = nd.value, true
+	}
+	return values, ok
+}
+
+// GetMany retrieves the entries stored under keys, locking every shard involved once. See Cache.GetMany.
+func (s *Sharded[K, V]) GetMany(keys []K) (items []Item[V], ok []bool) {
+	items, ok = make([]Item[V], len(keys)), make([]bool, len(keys))
+	for shard, idx := range s.group(keys) {
+		part, found := s.shards[shard].GetMany(pick(keys, idx))
+		for j, i := range idx {
+			items[i], ok[i] = part[j], found[j]
+		}
+	}
+	return items, ok
+}
+
+// PutMany stores the values under their keys, locking every shard involved once. See Cache.PutMany.
+// The order of the puts is kept within a shard, which is all that matters as keys never move between shards.
+func (s *Sharded[K, V]) PutMany(keys []K, values []V, ttls []time.Duration) (stored []bool) {
+	stored = make([]bool, len(keys))
+	for shard, idx := range s.group(keys) {
+		part := s.shards[shard].PutMany(pick(keys, idx), pick(values, idx), pick(ttls, idx))
+		for j, i := range idx {
+			stored[i] = part[j]
+		}
+	}
+	return stored
+}
+
+// EvictMany removes the entries stored under keys, locking every shard involved once. See Cache.EvictMany.
+func (s *Sharded[K, V]) EvictMany(keys []K) (values []V, ok []bool) {
+	values, ok = make([]V, len(keys)), make([]bool, len(keys))
+	for shard, idx := range s.group(keys) {
+		part, found := s.shards[shard].EvictMany(pick(keys, idx))
+		for j, i := range idx {
+			values[i], ok[i] = part[j], found[j]
+		}
+	}
+	return values, ok
+}
+
+// group returns the positions of the keys falling into every shard, in order.
+func (s *Sharded[K, V]) group(keys []K) map[int][]int {
+	groups := make(map[int][]int)
+	for i, key := range keys {
+		shard := int(s.hash(key) % uint64(len(s.shards)))
+		groups[shard] = append(groups[shard], i)
+	}
+	return groups
+}
+
+// pick returns the elements of s at the positions idx.
+func pick[T any](s []T, idx []int) []T {
+	picked := make([]T, len(idx))
+	for j, i := range idx {
+		picked[j] = s[i]
+	}
+	return picked
+}
@@ -0,0 +1,110 @@
+package lru
+
+import "time"
+
+// Mode is the condition under which PutIf stores a value.
+type Mode int
+
+const (
+	// IfAbsent stores the value only if the key has no live entry.
+	IfAbsent Mode = iota + 1
+	// IfPresent stores the value only if the key has a live entry.
+	IfPresent
+)
+
+// PutIf stores value under key like Put, but only if the condition of mode holds.
+// The condition is checked and the value stored atomically. Returns whether the value was stored.
+func (c *Cache[K, V]) PutIf(key K, value V, ttl time.Duration, mode Mode) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	nd, ok := c.data[key]
+	if live := ok && !nd.expired(time.Now()); live != (mode == IfPresent) {
+		return false
+	}
+	c.put(key, value, ttl)
+	return true
+}
+
+// PutIf stores value under key in the shard of the key if the condition of mode holds. See Cache.PutIf.
+func (s *Sharded[K, V]) PutIf(key K, value V, ttl time.Duration, mode Mode) bool {
+	return s.shard(key).PutIf(key, value, ttl, mode)
+}
+
+// PutIfVersion stores value under key like Put, but only if a live entry with the given version,
+// as returned in Item.Version, is stored under key. The version is checked and the value stored atomically,
+// which makes a compare-and-swap. Returns the version of the live entry stored under key after the call,
+// 0 if there is none, and whether the value was stored.
+func (c *Cache[K, V]) PutIfVersion(key K, value V, ttl time.Duration, version uint64) (current uint64, stored bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	nd, ok := c.data[key]
+	if !ok || nd.expired(time.Now()) {
+		return 0, false
+	}
+	if nd.version != version {
+		return nd.version, false
+	}
+	c.put(key, value, ttl)
+	if nd, ok = c.data[key]; !ok {
+		// The new value was not admitted.
+		return 0, true
+	}
+	return nd.version, true
+}
+
+// PutIfVersion stores value under key in the shard of the key if its entry has the given version. See Cache.PutIfVersion.
+func (s *Sharded[K, V]) PutIfVersion(key K, value V, ttl time.Duration, version uint64) (current uint64, stored bool) {
+	return s.shard(key).PutIfVersion(key, value, ttl, version)
+}
+
+// PeekItem retrieves the entry stored under key like Lookup, without marking it as recently used,
+// counting the read, removing it if it has expired or triggering a reload.
+func (c *Cache[K, V]) PeekItem(key K) (item Item[V], ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	nd, ok := c.data[key]
+	if !ok || nd.expired(time.Now()) {
+		return item, false
+	}
+	return Item[V]{Value: nd.value, ExpiresAt: nd.expiresAt, Refreshing: nd.refreshing, Version: nd.version}, true
+}
+
+// PeekItem retrieves the entry stored under key from the shard of the key. See Cache.PeekItem.
+func (s *Sharded[K, V]) PeekItem(key K) (item Item[V], ok bool) {
+	return s.shard(key).PeekItem(key)
+}
+
+// Expire sets the TTL of the entry stored under key without changing its value or recency.
+// A non-positive TTL means the entry never expires. Returns false if the key is not found or has expired.
+func (c *Cache[K, V]) Expire(key K, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	nd, ok := c.data[key]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if nd.expired(now) {
+		c.drop(nd, ReasonExpired)
+		return false
+	}
+	c.unindex(nd)
+	nd.expiresAt, nd.ttl = time.Time{}, 0
+	if ttl > 0 {
+		nd.expiresAt, nd.ttl = now.Add(ttl), ttl
+	}
+	c.index(nd)
+	if c.journal != nil {
+		c.journal.Put(key, nd.value, nd.expiresAt)
+	}
+	return true
+}
+
+// Expire sets the TTL of the entry stored under key in the shard of the key. See Cache.Expire.
+func (s *Sharded[K, V]) Expire(key K, ttl time.Duration) bool {
+	return s.shard(key).Expire(key, ttl)
+}
@@ -0,0 +1,88 @@
+package lru
+
+// EvictReason tells why an entry left the cache.
+type EvictReason int
+
+const (
+	// ReasonCapacity means the entry was evicted by the eviction policy to make room for another one.
+	ReasonCapacity EvictReason = iota + 1
+	// ReasonExpired means the TTL of the entry had passed.
+	ReasonExpired
+	// ReasonExplicit means the entry was removed with Evict.
+	ReasonExplicit
+	// ReasonCleared means the entry was removed with EvictAll.
+	ReasonCleared
+	// ReasonReplaced means Put stored a new value under the key of the entry.
+	ReasonReplaced
+)
+
+// String returns the lowercase name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonExplicit:
+		return "explicit"
+	case ReasonCleared:
+		return "cleared"
+	case ReasonReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// OnEvict registers fn to be called for every entry that leaves the cache, with the reason it left.
+// Callbacks run synchronously in the goroutine that removed the entries, after the cache lock is released,
+// so they may use the cache themselves.
+func (c *Cache[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.listeners = append(c.listeners, fn)
+}
+
+// OnEvict registers fn on every shard. See Cache.OnEvict.
+func (s *Sharded[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	for _, c := range s.shards {
+		c.OnEvict(fn)
+	}
+}
+
+// notify queues an eviction event to be delivered by unlock.
+func (c *Cache[K, V]) notify(nd *entry[K, V], reason EvictReason) {
+	if len(c.listeners) > 0 {
+		c.pending = append(c.pending, eviction[K, V]{key: nd.key, value: nd.value, reason: reason})
+	}
+}
+
+// unlock releases the cache lock, then syncs the journal and delivers the eviction events queued while it was held.
+func (c *Cache[K, V]) unlock() {
+	c.release()()
+}
+
+// release releases the cache lock and returns a function syncing the journal if writes were recorded
+// and delivering the eviction events queued while the lock was held.
+func (c *Cache[K, V]) release() (deliver func()) {
+	pending, listeners, unsynced := c.pending, c.listeners, c.unsynced
+	c.pending, c.unsynced = nil, false
+	c.mu.Unlock()
+
+	return func() {
+		if unsynced {
+			c.syncer.Sync()
+		}
+		for _, ev := range pending {
+			for _, fn := range listeners {
+				fn(ev.key, ev.value, ev.reason)
+			}
+		}
+	}
+}
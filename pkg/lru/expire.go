@@ -0,0 +1,82 @@
+package lru
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// ActiveExpiration configures the background expirer, which removes expired entries
+// that are never read again instead of waiting for the LRU policy to push them out.
+//
+// The algorithm follows Redis: every Interval the expirer samples SampleSize random entries
+// that have a TTL and deletes the expired ones. If more than Threshold of the sample was expired,
+// the cache most likely holds many more, so the round is repeated right away, for at most
+// a quarter of the interval.
+type ActiveExpiration struct {
+	Interval   time.Duration
+	SampleSize int
+	Threshold  float64
+}
+
+// WithActiveExpiration enables the background expirer. A non-positive interval disables it,
+// a non-positive sample size defaults to 20 and a non-positive threshold defaults to 0.25.
+func WithActiveExpiration[K comparable, V any](cfg ActiveExpiration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		if cfg.Interval <= 0 {
+			return
+		}
+		if cfg.SampleSize <= 0 {
+			cfg.SampleSize = 20
+		}
+		if cfg.Threshold <= 0 {
+			cfg.Threshold = 0.25
+		}
+		c.expiration = &cfg
+	}
+}
+
+func (c *Cache[K, V]) expireLoop() {
+	defer c.done.Done()
+
+	ticker := time.NewTicker(c.expiration.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(c.expiration.Interval / 4)
+			for c.expireSample() > c.expiration.Threshold {
+				if time.Now().After(deadline) {
+					break
+				}
+			}
+		}
+	}
+}
+
+// expireSample deletes the expired entries past their grace period among a random sample of entries with a TTL.
+// Returns the ratio of expired entries in the sample.
+func (c *Cache[K, V]) expireSample() float64 {
+	c.mu.Lock()
+	defer c.unlock()
+
+	n := min(c.expiration.SampleSize, len(c.ttls))
+	if n == 0 {
+		return 0
+	}
+	now := time.Now().Add(-c.grace)
+	expired := 0
+	for range n {
+		nd := c.ttls[rand.IntN(len(c.ttls))]
+		if nd.expired(now) {
+			c.drop(nd, ReasonExpired)
+			expired++
+			if len(c.ttls) == 0 {
+				break
+			}
+		}
+	}
+	return float64(expired) / float64(n)
+}
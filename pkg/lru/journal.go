@@ -0,0 +1,68 @@
+package lru
+
+import "time"
+
+// Journal records the writes made to a cache, e.g. to an append-only log it can be rebuilt from.
+// Its methods are called with the cache lock held, in the order the writes are applied,
+// so they must be fast and must not use the cache.
+// Entries removed because they expired or to make room for others are not recorded,
+// as replaying the writes in order removes them again.
+type Journal[K comparable, V any] interface {
+	// Put records that value was stored under key until expiresAt, zero if it never expires.
+	Put(key K, value V, expiresAt time.Time)
+	// Evict records that key was removed.
+	Evict(key K)
+	// EvictAll records that every entry was removed.
+	EvictAll()
+}
+
+// AccessJournal is implemented by journals that also record reads, so that the cache can be rebuilt
+// in recency order and not only in the order of the writes. Access is called like the methods of Journal.
+type AccessJournal[K comparable] interface {
+	// Access records that key was read, making its entry the most recently used one.
+	Access(key K)
+}
+
+// SyncJournal is implemented by journals that make the writes they recorded durable before the write returns.
+// Sync is called after the cache lock is released, in the goroutine of every operation that recorded writes,
+// so that the other users of the cache don't wait for it.
+type SyncJournal interface {
+	// Sync makes the writes recorded so far durable.
+	Sync()
+}
+
+// WithJournal records every Put, Evict and EvictAll of the cache in j, as well as the values stored
+// by GetOrLoad, refreshes and Restore. Sharded caches record each EvictAll once.
+// If j is also an AccessJournal, every read that finds an entry is recorded too,
+// and if it is a SyncJournal, it is synced after every operation that recorded writes.
+func WithJournal[K comparable, V any](j Journal[K, V]) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.journal = j
+		c.accesses, _ = j.(AccessJournal[K])
+		if syncer, ok := j.(SyncJournal); ok {
+			c.syncer = syncer
+			c.journal = tracked[K, V]{Journal: j, c: c}
+		}
+	}
+}
+
+// tracked marks the cache as unsynced whenever a write is recorded in its journal.
+type tracked[K comparable, V any] struct {
+	Journal[K, V]
+	c *Cache[K, V]
+}
+
+func (t tracked[K, V]) Put(key K, value V, expiresAt time.Time) {
+	t.c.unsynced = true
+	t.Journal.Put(key, value, expiresAt)
+}
+
+func (t tracked[K, V]) Evict(key K) {
+	t.c.unsynced = true
+	t.Journal.Evict(key)
+}
+
+func (t tracked[K, V]) EvictAll() {
+	t.c.unsynced = true
+	t.Journal.EvictAll()
+}
@@ -0,0 +1,70 @@
+package lru
+
+// lfu evicts the least frequently used entry in O(1): entries are grouped into buckets
+// of equal access frequency, kept in a list sorted by frequency, so the victim is always
+// the oldest entry of the first bucket.
+type lfu[K comparable, V any] struct {
+	root lfuBucket[K, V]
+}
+
+type lfuBucket[K comparable, V any] struct {
+	freq    uint64
+	entries *queue[K, V]
+	prev    *lfuBucket[K, V]
+	next    *lfuBucket[K, V]
+}
+
+func newLFU[K comparable, V any]() *lfu[K, V] {
+	p := &lfu[K, V]{}
+	p.root.prev, p.root.next = &p.root, &p.root
+	return p
+}
+
+// bucketAfter returns the bucket of the given frequency following prev, creating it if needed.
+func (p *lfu[K, V]) bucketAfter(prev *lfuBucket[K, V], freq uint64) *lfuBucket[K, V] {
+	if prev.next != &p.root && prev.next.freq == freq {
+		return prev.next
+	}
+	b := &lfuBucket[K, V]{freq: freq, entries: newQueue[K, V](), prev: prev, next: prev.next}
+	prev.next.prev = b
+	prev.next = b
+	return b
+}
+
+// unlink removes the entry from its bucket, dropping the bucket if it becomes empty.
+func (p *lfu[K, V]) unlink(nd *entry[K, V]) {
+	b := nd.bucket
+	b.entries.remove(nd)
+	nd.bucket = nil
+	if b.entries.len == 0 {
+		b.prev.next, b.next.prev = b.next, b.prev
+	}
+}
+
+func (p *lfu[K, V]) admit(nd *entry[K, V]) {
+	nd.bucket = p.bucketAfter(&p.root, 1)
+	nd.bucket.entries.pushBack(nd)
+}
+
+func (p *lfu[K, V]) access(nd *entry[K, V]) {
+	cur := nd.bucket
+	next := p.bucketAfter(cur, cur.freq+1)
+	p.unlink(nd)
+	nd.bucket = next
+	next.entries.pushBack(nd)
+}
+
+func (p *lfu[K, V]) victim(K) *entry[K, V] {
+	if p.root.next == &p.root {
+		return nil
+	}
+	return p.root.next.entries.front()
+}
+
+func (p *lfu[K, V]) remove(nd *entry[K, V], evicted bool) {
+	p.unlink(nd)
+}
+
+func (p *lfu[K, V]) reset() {
+	p.root.prev, p.root.next = &p.root, &p.root
+}
@@ -0,0 +1,165 @@
+package lru
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// call is a load in flight shared by every caller of GetOrLoad waiting for the same key.
+type call[V any] struct {
+	done    chan struct{}
+	value   V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// group collapses concurrent loads of the same key into a single call, like golang.org/x/sync/singleflight.
+// The load runs in its own goroutine with a context that is only canceled once every waiting caller has given up.
+type group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+func (g *group[K, V]) do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	cl, ok := g.calls[key]
+	if !ok {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		cl = &call[V]{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = cl
+		go g.run(loadCtx, key, cl, fn)
+	}
+	cl.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if cl.waiters--; cl.waiters == 0 {
+			cl.cancel()
+			g.forget(key, cl)
+		}
+		g.mu.Unlock()
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (g *group[K, V]) run(ctx context.Context, key K, cl *call[V], fn func(ctx context.Context) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			cl.err = fmt.Errorf("lru: loader panicked: %v", r)
+		}
+		g.mu.Lock()
+		g.forget(key, cl)
+		g.mu.Unlock()
+		cl.cancel()
+		close(cl.done)
+	}()
+	cl.value, cl.err = fn(ctx)
+}
+
+// forget removes cl from the calls in flight unless a new call for the key has already replaced it.
+func (g *group[K, V]) forget(key K, cl *call[V]) {
+	if g.calls[key] == cl {
+		delete(g.calls, key)
+	}
+}
+
+// failure is a loader error remembered by negative caching.
+type failure struct {
+	err       error
+	expiresAt time.Time
+}
+
+// WithNegativeCaching makes GetOrLoad remember loader errors for ttl, returning them again
+// without calling the loader until they expire or a value is stored under the key.
+// At most as many errors as the capacity of the cache, and no less than 64, are remembered.
+func WithNegativeCaching[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.negativeTTL = ttl
+		c.failures = make(map[K]failure)
+	}
+}
+
+// GetOrLoad retrieves the value stored under key like Get. On a miss it calls load,
+// stores the value it returns with the returned TTL and returns it.
+// Concurrent misses for the same key share a single call of load, which keeps running as long as
+// at least one caller is waiting for it; a caller whose ctx is done returns ctx.Err() right away.
+// Errors returned by load are passed to every waiting caller and are not cached unless WithNegativeCaching is used.
+// A panic in load is returned as an error.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, load func(ctx context.Context) (V, time.Duration, error)) (V, error) {
+	if value, _, ok := c.Get(key); ok {
+		return value, nil
+	}
+	return c.flights.do(ctx, key, func(ctx context.Context) (V, error) {
+		// Another load may have completed between the miss and the start of this one.
+		if value, err, ok := c.loaded(key); ok {
+			return value, err
+		}
+		value, ttl, err := load(ctx)
+		if err != nil {
+			c.fail(key, err)
+			return value, err
+		}
+		c.Put(key, value, ttl)
+		return value, nil
+	})
+}
+
+// GetOrLoad loads the value of key through the shard of the key. See Cache.GetOrLoad.
+func (s *Sharded[K, V]) GetOrLoad(ctx context.Context, key K, load func(ctx context.Context) (V, time.Duration, error)) (V, error) {
+	return s.shard(key).GetOrLoad(ctx, key, load)
+}
+
+// loaded returns the live value or the remembered loader error of key, without counting a read
+// or updating the recency of the entry.
+func (c *Cache[K, V]) loaded(key K) (value V, err error, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if nd, ok := c.data[key]; ok && !nd.expired(now) {
+		return nd.value, nil, true
+	}
+	if f, ok := c.failures[key]; ok {
+		if f.expiresAt.After(now) {
+			return value, f.err, true
+		}
+		delete(c.failures, key)
+	}
+	return value, nil, false
+}
+
+// fail remembers a loader error if negative caching is enabled, making room for it if needed.
+func (c *Cache[K, V]) fail(key K, err error) {
+	if c.failures == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if limit := max(c.capacity, 64); len(c.failures) >= limit {
+		for k, f := range c.failures {
+			if !f.expiresAt.After(now) {
+				delete(c.failures, k)
+			}
+		}
+		for k := range c.failures {
+			if len(c.failures) < limit {
+				break
+			}
+			delete(c.failures, k)
+		}
+	}
+	c.failures[key] = failure{err: err, expiresAt: now.Add(c.negativeTTL)}
+}
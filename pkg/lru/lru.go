@@ -0,0 +1,421 @@
+// Package lru provides a generic, type-safe LRU cache with per-entry TTL and concurrency support.
+// It is the storage engine behind the HTTP service and can be embedded directly in Go programs.
+package lru
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+	prev      *entry[K, V]
+	next      *entry[K, V]
+	// ttlIdx is the position of the entry in Cache.ttls, valid only when expiresAt is set.
+	ttlIdx int
+	// wprev and wnext link the entry into a timing wheel slot.
+	wprev *entry[K, V]
+	wnext *entry[K, V]
+	// pprev and pnext link the entry into a list of the eviction policy.
+	pprev *entry[K, V]
+	pnext *entry[K, V]
+	// bucket is the frequency bucket of the entry under PolicyLFU.
+	bucket *lfuBucket[K, V]
+	// visited is the visited flag of the entry under PolicySIEVE.
+	visited bool
+	// queue tells which list holds the entry under Policy2Q and PolicyARC.
+	queue uint8
+	// weight is the cost of the entry computed by the weigher.
+	weight int64
+	// ttl is the TTL the entry was stored with, used to find its refresh-ahead window.
+	ttl time.Duration
+	// refreshing is set while the loader reloads the entry in the background.
+	refreshing bool
+	// seq is the position of the entry in the list, increasing from the least to the most recently used entry.
+	seq uint64
+	// version identifies the value of the entry, see Item.Version.
+	version uint64
+}
+
+// expired reports whether the entry has a TTL that has already passed.
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && e.expiresAt.Before(now)
+}
+
+// Cache is a concurrency-safe cache holding at most capacity entries, or entries of at most
+// a given total weight (see WithMaxWeight).
+// Entries are kept in a doubly-linked list ordered from the least to the most recently used,
+// with a map from keys to list nodes for O(1) lookups. The least recently used entry is evicted
+// when the cache is full, unless another policy is selected with WithPolicy.
+type Cache[K comparable, V any] struct {
+	capacity int
+	data     map[K]*entry[K, V]
+	left     *entry[K, V]
+	right    *entry[K, V]
+	// ttls holds every entry that has an expiration time so that they can be sampled uniformly.
+	ttls []*entry[K, V]
+	// seq is the last position given to an entry appended to the list.
+	seq uint64
+	// version is the last version given to a stored value.
+	version uint64
+	mu      sync.Mutex
+
+	policy     evictor[K, V]
+	admission  *admission[K]
+	maxWeight  int64
+	weigher    func(K, V) int64
+	counters   counters
+	expiration *ActiveExpiration
+	wheel      *wheel[K, V]
+	listeners  []func(K, V, EvictReason)
+	pending    []eviction[K, V]
+	stop       chan struct{}
+	done       sync.WaitGroup
+	closeOnce  sync.Once
+	// flights are the loads in progress started by GetOrLoad.
+	flights group[K, V]
+	// failures are the loader errors remembered by negative caching.
+	failures    map[K]failure
+	negativeTTL time.Duration
+	// loader reloads entries for refresh-ahead and stale-while-revalidate.
+	loader       func(ctx context.Context, key K) (V, time.Duration, error)
+	refreshAhead float64
+	grace        time.Duration
+	journal      Journal[K, V]
+	accesses     AccessJournal[K]
+	syncer       SyncJournal
+	// unsynced is set when a write was journaled under the lock, for release to sync the journal.
+	unsynced bool
+	// ctx is passed to the loader and canceled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New creates a new LRU cache with the specified capacity, applying the given options.
+// Caches started with background work (e.g. WithActiveExpiration) must be stopped with Close.
+func New[K comparable, V any](capacity int, opts ...Option[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		capacity: capacity,
+		data:     make(map[K]*entry[K, V], capacity),
+		left:     &entry[K, V]{},
+		right:    &entry[K, V]{},
+		stop:     make(chan struct{}),
+	}
+	c.left.next, c.right.prev = c.right, c.left
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.policy = &lruPolicy[K, V]{c: c}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.admission != nil {
+		c.admission.sketch = newSketch(c.expectedEntries())
+	}
+	if c.loader == nil {
+		c.grace = 0
+	}
+	if c.expiration != nil {
+		c.done.Add(1)
+		go c.expireLoop()
+	}
+	if c.wheel != nil {
+		c.wheel.grace = c.grace
+		c.done.Add(1)
+		go c.wheelLoop()
+	}
+	return c
+}
+
+// Close stops the background goroutines of the cache, including the reloads in progress, and waits for them to exit.
+// The cache remains usable afterwards. Close is safe to call multiple times.
+func (c *Cache[K, V]) Close() error {
+	c.closeOnce.Do(func() {
+		// The lock orders the close with refresh, which only starts reloads while the cache is open.
+		c.mu.Lock()
+		close(c.stop)
+		c.mu.Unlock()
+		c.cancel()
+		c.done.Wait()
+	})
+	return nil
+}
+
+// Put stores value under key with the specified TTL. A non-positive TTL means the entry never expires.
+// If the key already exists, the existing entry is replaced and becomes the most recently used one.
+// When the cache is full, entries picked by the eviction policy are evicted to make room.
+func (c *Cache[K, V]) Put(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.put(key, value, ttl)
+}
+
+// put stores the entry and journals it. Returns false if the entry was not admitted.
+func (c *Cache[K, V]) put(key K, value V, ttl time.Duration) bool {
+	_, replacing := c.data[key]
+	nd := c.set(key, value, ttl)
+	c.record(key, nd, replacing)
+	return nd != nil
+}
+
+// record journals the outcome of a put of key, nd being the stored entry or nil if it was not admitted.
+func (c *Cache[K, V]) record(key K, nd *entry[K, V], replacing bool) {
+	if c.journal == nil {
+		return
+	}
+	switch {
+	case nd != nil:
+		c.journal.Put(key, nd.value, nd.expiresAt)
+	case replacing:
+		// The old value was dropped but the new one did not fit.
+		c.journal.Evict(key)
+	}
+}
+
+// set stores value under key, evicting other entries to make room. Returns nil if the value was not stored.
+// An existing entry is updated in place by replace, so that it keeps its state in the eviction policy.
+func (c *Cache[K, V]) set(key K, value V, ttl time.Duration) *entry[K, V] {
+	c.counters.puts.Add(1)
+	delete(c.failures, key)
+	if c.admission != nil {
+		c.admission.record(key)
+	}
+	var weight int64
+	if c.weigher != nil {
+		weight = c.weigher(key, value)
+	}
+	if old, ok := c.data[key]; ok {
+		return c.replace(old, value, ttl, weight)
+	}
+	if c.weigher != nil && weight > c.maxWeight {
+		return nil
+	}
+	c.version++
+	nd := &entry[K, V]{key: key, value: value, version: c.version, weight: weight}
+	if ttl > 0 {
+		nd.expiresAt = time.Now().Add(ttl)
+		nd.ttl = ttl
+	}
+	// Only the first victim is contested, so that a rejected key never evicts anything.
+	contested := c.admission == nil
+	for c.full(nd.weight) {
+		victim := c.policy.victim(key)
+		if victim == nil {
+			return nil
+		}
+		if !contested {
+			if !c.admission.admit(key, victim.key) {
+				c.counters.rejected.Add(1)
+				return nil
+			}
+			c.counters.admitted.Add(1)
+			contested = true
+		}
+		c.drop(victim, ReasonCapacity)
+	}
+	c.add(nd)
+	if c.admission != nil {
+		c.admission.fit(len(c.data))
+	}
+	return nd
+}
+
+// replace stores a new value in the existing entry nd, which becomes the most recently used one.
+// The rewrite counts as an access for the eviction policy rather than as a new entry, otherwise rewriting
+// a hot key, such as a counter, would send it back to the probation queue of LFU, 2Q or ARC.
+// Returns nil if the value does not fit in the cache, in which case the entry is removed.
+func (c *Cache[K, V]) replace(nd *entry[K, V], value V, ttl time.Duration, weight int64) *entry[K, V] {
+	if c.weigher != nil && weight > c.maxWeight {
+		c.drop(nd, ReasonReplaced)
+		return nil
+	}
+	c.notify(nd, ReasonReplaced)
+	c.unindex(nd)
+	c.counters.weight.Add(weight - nd.weight)
+	c.version++
+	nd.value, nd.weight, nd.version, nd.refreshing = value, weight, c.version, false
+	nd.expiresAt, nd.ttl = time.Time{}, 0
+	if ttl > 0 {
+		nd.expiresAt = time.Now().Add(ttl)
+		nd.ttl = ttl
+	}
+	c.index(nd)
+	c.remove(nd)
+	c.insert(nd)
+	c.policy.access(nd)
+	for c.maxWeight > 0 && c.counters.weight.Load() > c.maxWeight {
+		victim := c.policy.victim(nd.key)
+		if victim == nil || victim == nd {
+			c.drop(nd, ReasonCapacity)
+			return nil
+		}
+		c.drop(victim, ReasonCapacity)
+	}
+	return nd
+}
+
+// Get retrieves the value stored under key and marks it as the most recently used one.
+// Returns the value, its expiration time (zero if the entry never expires) and
+// false if the key is not found or has expired. See Lookup for stale entries.
+func (c *Cache[K, V]) Get(key K) (value V, expiresAt time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(key)
+	return item.Value, item.ExpiresAt, ok
+}
+
+// Peek retrieves the value stored under key like Get, without marking it as recently used,
+// counting the read or removing it if it has expired.
+func (c *Cache[K, V]) Peek(key K) (value V, expiresAt time.Time, ok bool) {
+	item, ok := c.PeekItem(key)
+	return item.Value, item.ExpiresAt, ok
+}
+
+// All returns the contents of the cache as two slices of keys and values ordered
+// from the least to the most recently used entry. Pairs share the same index.
+func (c *Cache[K, V]) All() (keys []K, values []V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys = make([]K, 0, len(c.data))
+	values = make([]V, 0, len(c.data))
+	for nd := c.left.next; nd != c.right; nd = nd.next {
+		keys = append(keys, nd.key)
+		values = append(values, nd.value)
+	}
+	return keys, values
+}
+
+// Evict removes the entry stored under key.
+// Returns the removed value and false if the key is not found or has expired.
+func (c *Cache[K, V]) Evict(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	delete(c.failures, key)
+	nd, ok := c.data[key]
+	if !ok {
+		return value, false
+	}
+	if c.journal != nil {
+		c.journal.Evict(key)
+	}
+	if nd.expired(time.Now()) {
+		c.drop(nd, ReasonExpired)
+		return value, false
+	}
+	c.drop(nd, ReasonExplicit)
+	return nd.value, true
+}
+
+// EvictAll removes every entry from the cache.
+func (c *Cache[K, V]) EvictAll() {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.clear()
+	if c.journal != nil {
+		c.journal.EvictAll()
+	}
+}
+
+// clear removes every entry, reporting them to the OnEvict listeners.
+func (c *Cache[K, V]) clear() {
+	for nd := c.left.next; nd != c.right; nd = nd.next {
+		c.notify(nd, ReasonCleared)
+	}
+	c.counters.removals.Add(uint64(len(c.data)))
+	c.counters.size.Store(0)
+	c.left.next, c.right.prev = c.right, c.left
+	clear(c.data)
+	clear(c.failures)
+	c.counters.weight.Store(0)
+	clear(c.ttls)
+	c.ttls = c.ttls[:0]
+	if c.wheel != nil {
+		c.wheel.reset()
+	}
+	c.policy.reset()
+}
+
+// Len returns the number of entries currently stored in the cache, including expired ones
+// that have not been removed yet.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.data)
+}
+
+// Cap returns the maximum number of entries the cache can hold.
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+func (c *Cache[K, V]) insert(nd *entry[K, V]) {
+	c.seq++
+	nd.seq = c.seq
+	prev, nxt := c.right.prev, c.right
+	nxt.prev = nd
+	prev.next = nd
+	nd.next, nd.prev = nxt, prev
+}
+
+func (c *Cache[K, V]) remove(nd *entry[K, V]) {
+	prev, nxt := nd.prev, nd.next
+	prev.next, nxt.prev = nxt, prev
+}
+
+// add stores a new entry in the map, appends it to the list as the most recently used one,
+// hands it to the eviction policy and indexes it for expiration if it has a TTL.
+func (c *Cache[K, V]) add(nd *entry[K, V]) {
+	c.data[nd.key] = nd
+	c.counters.size.Add(1)
+	c.counters.weight.Add(nd.weight)
+	c.insert(nd)
+	c.policy.admit(nd)
+	c.index(nd)
+}
+
+// drop unlinks the entry from the list, the eviction policy, the expiration index and drops it from the map.
+// The removal is reported to the OnEvict listeners with the given reason.
+func (c *Cache[K, V]) drop(nd *entry[K, V], reason EvictReason) {
+	c.remove(nd)
+	c.policy.remove(nd, reason == ReasonCapacity)
+	delete(c.data, nd.key)
+	c.counters.size.Add(-1)
+	c.counters.weight.Add(-nd.weight)
+	c.counters.count(reason)
+	c.unindex(nd)
+	c.notify(nd, reason)
+}
+
+// index adds the entry to the expiration index if it has a TTL.
+func (c *Cache[K, V]) index(nd *entry[K, V]) {
+	if nd.expiresAt.IsZero() {
+		return
+	}
+	nd.ttlIdx = len(c.ttls)
+	c.ttls = append(c.ttls, nd)
+	if c.wheel != nil {
+		c.wheel.schedule(nd)
+	}
+}
+
+// unindex removes the entry from the expiration index if it has a TTL.
+func (c *Cache[K, V]) unindex(nd *entry[K, V]) {
+	if nd.expiresAt.IsZero() {
+		return
+	}
+	last := len(c.ttls) - 1
+	c.ttls[nd.ttlIdx] = c.ttls[last]
+	c.ttls[nd.ttlIdx].ttlIdx = nd.ttlIdx
+	c.ttls[last] = nil
+	c.ttls = c.ttls[:last]
+	if c.wheel != nil {
+		c.wheel.unschedule(nd)
+	}
+}
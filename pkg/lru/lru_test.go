@@ -0,0 +1,827 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"lru-cache/pkg/errs"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTypedValues verifies that the cache stores and returns values of its type parameter without boxing.
+func TestTypedValues(t *testing.T) {
+	c := New[int, string](3)
+
+	c.Put(1, "one", time.Hour)
+	c.Put(2, "two", 0)
+
+	got, expiresAt, ok := c.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, "one", got)
+	assert.False(t, expiresAt.IsZero())
+
+	got, expiresAt, ok = c.Get(2)
+	assert.True(t, ok)
+	assert.Equal(t, "two", got)
+	assert.True(t, expiresAt.IsZero())
+
+	_, _, ok = c.Get(3)
+	assert.False(t, ok)
+}
+
+// TestGetRefreshesRecency verifies that reading a key protects it from being evicted next.
+func TestGetRefreshesRecency(t *testing.T) {
+	c := New[string, int](2)
+
+	c.Put("a", 1, time.Hour)
+	c.Put("b", 2, time.Hour)
+	c.Get("a")
+	c.Put("c", 3, time.Hour)
+
+	_, _, ok := c.Get("b")
+	assert.False(t, ok)
+	_, _, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+// TestAllIsOrderedByRecency verifies that All lists entries from the least to the most recently used.
+func TestAllIsOrderedByRecency(t *testing.T) {
+	c := New[string, int](5)
+	for i, k := range []string{"a", "b", "c"} {
+		c.Put(k, i, time.Hour)
+	}
+	c.Get("a")
+
+	keys, values := c.All()
+	assert.Equal(t, []string{"b", "c", "a"}, keys)
+	assert.Equal(t, []int{1, 2, 0}, values)
+}
+
+// TestEvictExpired verifies that an expired entry is dropped by Evict but not reported as evicted.
+func TestEvictExpired(t *testing.T) {
+	c := New[string, int](5)
+	c.Put("a", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.Evict("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+// TestActiveExpiration verifies that the background expirer removes expired entries that are never read.
+func TestActiveExpiration(t *testing.T) {
+	c := New(100, WithActiveExpiration[int, int](ActiveExpiration{Interval: 5 * time.Millisecond}))
+	defer c.Close()
+
+	for i := range 50 {
+		c.Put(i, i, 10*time.Millisecond)
+	}
+	c.Put(-1, -1, time.Hour)
+
+	assert.Eventually(t, func() bool { return c.Len() == 1 }, time.Second, 10*time.Millisecond)
+	_, _, ok := c.Get(-1)
+	assert.True(t, ok)
+}
+
+// TestWheelExpiresOnTime verifies that the timing wheel expires entries at their tick, across levels and the overflow list.
+func TestWheelExpiresOnTime(t *testing.T) {
+	w := newWheel[int, int](time.Millisecond)
+	ticks := []int{1, 5, 63, 64, 65, 200, 4095, 4096, 5000, 300000, 1 << 24, 1<<24 + 70}
+
+	expired := map[int]uint64{}
+	for _, tick := range ticks {
+		w.schedule(&entry[int, int]{key: tick, expiresAt: w.origin.Add(time.Duration(tick) * time.Millisecond)})
+	}
+	for _, tick := range ticks {
+		w.advance(w.origin.Add(time.Duration(tick)*time.Millisecond), func(nd *entry[int, int]) {
+			expired[nd.key] = w.current
+		})
+	}
+
+	assert.Len(t, expired, len(ticks))
+	for _, tick := range ticks {
+		assert.Equal(t, uint64(tick), expired[tick], "tick %d", tick)
+	}
+}
+
+// TestTimingWheel verifies that the cache removes expired entries in the background when the timing wheel is enabled.
+func TestTimingWheel(t *testing.T) {
+	c := New(100, WithTimingWheel[int, int](time.Millisecond))
+	defer c.Close()
+
+	for i := range 50 {
+		c.Put(i, i, time.Duration(i%5+1)*time.Millisecond)
+	}
+	c.Put(-1, -1, time.Hour)
+	c.Evict(3)
+
+	assert.Eventually(t, func() bool { return c.Len() == 1 }, time.Second, 5*time.Millisecond)
+	c.EvictAll()
+	c.Put(1, 1, time.Millisecond)
+	assert.Eventually(t, func() bool { return c.Len() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+// TestParsePolicy verifies that policy names are parsed case-insensitively and unknown ones are rejected.
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("ARC")
+	assert.NoError(t, err)
+	assert.Equal(t, PolicyARC, p)
+
+	_, err = ParsePolicy("mru")
+	assert.ErrorIs(t, err, errs.ErrUnknownPolicy)
+}
+
+// TestLFUEvictsLeastFrequent verifies that LFU evicts the least frequently read key, oldest first among equals.
+func TestLFUEvictsLeastFrequent(t *testing.T) {
+	c := New(3, WithPolicy[string, int](PolicyLFU))
+	c.Put("a", 1, 0)
+	c.Put("b", 2, 0)
+	c.Put("c", 3, 0)
+	c.Get("a")
+	c.Get("a")
+	c.Get("c")
+	c.Put("d", 4, 0)
+	c.Put("e", 5, 0)
+
+	keys, _ := c.All()
+	assert.ElementsMatch(t, []string{"a", "c", "e"}, keys)
+}
+
+// TestSIEVESparesVisited verifies that SIEVE spares visited entries once and evicts the oldest unvisited one.
+func TestSIEVESparesVisited(t *testing.T) {
+	c := New(3, WithPolicy[string, int](PolicySIEVE))
+	c.Put("a", 1, 0)
+	c.Put("b", 2, 0)
+	c.Put("c", 3, 0)
+	c.Get("a")
+	c.Put("d", 4, 0)
+
+	keys, _ := c.All()
+	assert.ElementsMatch(t, []string{"a", "c", "d"}, keys)
+}
+
+// TestScanResistance verifies that every policy but LRU keeps a small hot set, read twice a round, cached
+// while a scan goes through the cache in between.
+func TestScanResistance(t *testing.T) {
+	for _, policy := range []Policy{PolicyLRU, PolicyLFU, PolicySIEVE, Policy2Q, PolicyARC} {
+		t.Run(string(policy), func(t *testing.T) {
+			c := New(10, WithPolicy[int, int](policy))
+			request := func(key int) bool {
+				if _, _, ok := c.Get(key); ok {
+					return true
+				}
+				c.Put(key, key, 0)
+				return false
+			}
+
+			hits, scan := 0, 1000
+			for round := range 100 {
+				for key := range 5 {
+					if request(key) && round >= 50 {
+						hits++
+					}
+				}
+				for key := range 5 {
+					request(key)
+				}
+				for range 8 {
+					request(scan)
+					scan++
+				}
+			}
+
+			if policy == PolicyLRU {
+				assert.Zero(t, hits)
+			} else {
+				assert.Greater(t, hits, 240)
+			}
+			assert.Equal(t, 10, c.Len())
+		})
+	}
+}
+
+// TestRewriteKeepsPolicyState verifies that rewriting a hot key counts as an access for the policies
+// that protect frequently used entries, so that it survives a scan instead of being demoted like a new key.
+func TestRewriteKeepsPolicyState(t *testing.T) {
+	for _, policy := range []Policy{PolicyLFU, Policy2Q, PolicyARC} {
+		t.Run(string(policy), func(t *testing.T) {
+			c := New(10, WithPolicy[int, int](policy))
+			// Bring the key back after an eviction, which is how 2Q and ARC tell it is reused, then read it.
+			c.Put(0, 0, 0)
+			for key := range 10 {
+				c.Put(100+key, key, 0)
+			}
+			c.Put(0, 0, 0)
+			for range 3 {
+				c.Get(0)
+			}
+
+			for i := range 10 {
+				c.Put(0, i, 0)
+				_, err := c.Update(0, 0, func(v int, ok bool) (int, error) { return v + 1, nil })
+				assert.NoError(t, err)
+			}
+			for key := range 100 {
+				c.Put(1000+key, key, 0)
+			}
+
+			value, _, ok := c.Get(0)
+			assert.True(t, ok)
+			assert.Equal(t, 10, value)
+			assert.Equal(t, 10, c.Len())
+		})
+	}
+}
+
+// TestPoliciesRandomOps verifies that every policy keeps its bookkeeping consistent under a random mix of operations.
+func TestPoliciesRandomOps(t *testing.T) {
+	for _, policy := range []Policy{PolicyLRU, PolicyLFU, PolicySIEVE, Policy2Q, PolicyARC} {
+		t.Run(string(policy), func(t *testing.T) {
+			c := New(16, WithPolicy[int, int](policy))
+			r := rand.New(rand.NewPCG(1, 2))
+			for range 20000 {
+				key := r.IntN(64)
+				switch op := r.IntN(100); {
+				case op < 50:
+					c.Get(key)
+				case op < 90:
+					c.Put(key, key, time.Duration(r.IntN(3))*time.Hour)
+				case op < 99:
+					c.Evict(key)
+				default:
+					c.EvictAll()
+				}
+				assert.LessOrEqual(t, c.Len(), 16)
+			}
+			keys, values := c.All()
+			assert.Equal(t, keys, values)
+		})
+	}
+}
+
+// TestAdmissionRejectsOneHitWonders verifies that the admission filter keeps keys read twice a round cached
+// while many keys requested only once are stored in between.
+func TestAdmissionRejectsOneHitWonders(t *testing.T) {
+	c := New(10, WithAdmission[int, int](func(k int) uint64 { return uint64(k) * 0x9E3779B97F4A7C15 }))
+	request := func(key int) bool {
+		if _, _, ok := c.Get(key); ok {
+			return true
+		}
+		c.Put(key, key, 0)
+		return false
+	}
+
+	hits, wonder := 0, 1000
+	for round := range 100 {
+		for key := range 8 {
+			if request(key) && round >= 50 {
+				hits++
+			}
+			request(key)
+		}
+		for range 20 {
+			request(wonder)
+			wonder++
+		}
+	}
+
+	assert.Greater(t, hits, 350)
+	assert.Greater(t, c.Stats().Rejected, uint64(1500))
+}
+
+// TestAdmissionFirstVictim verifies that a key needing several entries evicted is only compared with the first victim,
+// so that it is either stored or rejected without evicting anything.
+func TestAdmissionFirstVictim(t *testing.T) {
+	hash := func(k string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(k))
+		return h.Sum64()
+	}
+	weigh := func(k string, v string) int64 { return int64(len(v)) }
+	c := New(0, WithMaxWeight(10, weigh), WithAdmission[string, string](hash))
+	c.Put("a", "aaaa", 0)
+	c.Put("b", "bbbb", 0)
+	for range 5 {
+		c.Get("b")
+	}
+	c.Get("c")
+	c.Get("c")
+
+	// c was seen more often than a, the first victim, but less often than b.
+	c.Put("c", "cccccccc", 0)
+	keys, _ := c.All()
+	assert.Equal(t, []string{"c"}, keys)
+	assert.Equal(t, uint64(1), c.Stats().Admitted)
+	assert.Zero(t, c.Stats().Rejected)
+
+	for range 5 {
+		c.Get("c")
+	}
+	c.Put("d", "dddddddddd", 0)
+	keys, _ = c.All()
+	assert.Equal(t, []string{"c"}, keys)
+	assert.Equal(t, uint64(1), c.Stats().Rejected)
+}
+
+// TestAdmissionSketchSize verifies that the admission sketch is sized once the options have run,
+// from the weight limit of the shard when the cache is bounded by weight only, and grows with the cache.
+func TestAdmissionSketchSize(t *testing.T) {
+	hash := func(k int) uint64 { return uint64(k) * 0x9E3779B97F4A7C15 }
+	weigh := func(k, v int) int64 { return entryWeight }
+
+	c := New(0, WithAdmission[int, int](hash), WithMaxWeight(1000*entryWeight, weigh))
+	assert.Equal(t, 1000, c.admission.sketch.capacity)
+	assert.Equal(t, 10, New(10, WithAdmission[int, int](hash), WithMaxWeight(1000*entryWeight, weigh)).admission.sketch.capacity)
+	assert.Equal(t, maxExpectedEntries, New(0, WithMaxWeight(1<<40, weigh), WithAdmission[int, int](hash)).admission.sketch.capacity)
+
+	s := NewSharded(0, 4, hash, WithAdmission[int, int](hash), WithMaxWeight(1000*entryWeight, weigh))
+	for _, shard := range s.shards {
+		assert.Equal(t, 250, shard.admission.sketch.capacity)
+		assert.Equal(t, int64(250*entryWeight), shard.maxWeight)
+	}
+
+	c = New(0, WithMaxWeight(1000*entryWeight, func(k, v int) int64 { return 1 }), WithAdmission[int, int](hash))
+	for i := range 1500 {
+		c.Put(i, i, 0)
+	}
+	assert.Equal(t, 1500, c.Len())
+	assert.GreaterOrEqual(t, c.admission.sketch.capacity, 1500)
+}
+
+// TestMaxWeight verifies that entries are evicted until the new one fits in the weight limit
+// and that entries heavier than the limit are not stored.
+func TestMaxWeight(t *testing.T) {
+	c := New(0, WithMaxWeight(10, func(k string, v string) int64 { return int64(len(v)) }))
+	c.Put("a", "aaaa", 0)
+	c.Put("b", "bbbb", 0)
+	assert.Equal(t, int64(8), c.Stats().Weight)
+
+	c.Put("c", "cccccc", 0)
+	keys, _ := c.All()
+	assert.Equal(t, []string{"b", "c"}, keys)
+	assert.Equal(t, int64(10), c.Stats().Weight)
+
+	c.Put("d", "ddddddddddd", 0)
+	_, _, ok := c.Get("d")
+	assert.False(t, ok)
+
+	c.Evict("b")
+	assert.Equal(t, int64(6), c.Stats().Weight)
+	assert.Equal(t, int64(10), c.Stats().MaxWeight)
+}
+
+// TestOnEvict verifies that listeners are told about every removed entry with the right reason,
+// and that they may use the cache since they run without the lock held.
+func TestOnEvict(t *testing.T) {
+	c := New[string, int](2)
+	got := map[string]EvictReason{}
+	c.OnEvict(func(key string, value int, reason EvictReason) {
+		got[fmt.Sprintf("%s=%d", key, value)] = reason
+		c.Len()
+	})
+
+	c.Put("a", 1, 0)
+	c.Put("a", 2, 0)
+	c.Put("b", 3, 0)
+	c.Put("c", 4, 0)
+	c.Evict("b")
+	c.Put("d", 5, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	c.Get("d")
+	c.Put("e", 6, 0)
+	c.EvictAll()
+
+	assert.Equal(t, map[string]EvictReason{
+		"a=1": ReasonReplaced,
+		"a=2": ReasonCapacity,
+		"b=3": ReasonExplicit,
+		"d=5": ReasonExpired,
+		"c=4": ReasonCleared,
+		"e=6": ReasonCleared,
+	}, got)
+	assert.Equal(t, "capacity", ReasonCapacity.String())
+}
+
+// TestGetOrLoad checks that concurrent misses share one loader call and that loader errors
+// are only remembered with negative caching.
+func TestGetOrLoad(t *testing.T) {
+	c := New[string, int](10)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (int, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return 42, time.Minute, nil
+	}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(ctx, "a", load)
+			assert.NoError(t, err)
+			assert.Equal(t, 42, v)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+	v, _, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 42, v)
+
+	errLoad := errors.New("backend is down")
+	failing := func(ctx context.Context) (int, time.Duration, error) {
+		calls.Add(1)
+		return 0, 0, errLoad
+	}
+	calls.Store(0)
+	_, err := c.GetOrLoad(ctx, "b", failing)
+	assert.ErrorIs(t, err, errLoad)
+	_, err = c.GetOrLoad(ctx, "b", failing)
+	assert.ErrorIs(t, err, errLoad)
+	assert.Equal(t, int32(2), calls.Load())
+	_, _, ok = c.Get("b")
+	assert.False(t, ok)
+
+	c = New(10, WithNegativeCaching[string, int](time.Hour))
+	calls.Store(0)
+	_, err = c.GetOrLoad(ctx, "b", failing)
+	assert.ErrorIs(t, err, errLoad)
+	_, err = c.GetOrLoad(ctx, "b", failing)
+	assert.ErrorIs(t, err, errLoad)
+	assert.Equal(t, int32(1), calls.Load())
+	c.Put("b", 7, 0)
+	v, err = c.GetOrLoad(ctx, "b", failing)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, v)
+
+	blocked := make(chan struct{})
+	canceled, cancel := context.WithCancel(ctx)
+	go func() {
+		<-blocked
+		cancel()
+	}()
+	_, err = c.GetOrLoad(canceled, "c", func(ctx context.Context) (int, time.Duration, error) {
+		close(blocked)
+		<-ctx.Done()
+		return 0, 0, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestRefreshAhead checks that a read near the end of the TTL returns the current value
+// and reloads the entry once in the background.
+func TestRefreshAhead(t *testing.T) {
+	var loads atomic.Int32
+	c := New(10, WithLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+		return int(loads.Add(1)) * 10, time.Hour, nil
+	}), WithRefreshAhead[string, int](0.5))
+
+	c.Put("a", 1, 60*time.Millisecond)
+	item, ok := c.Lookup("a")
+	assert.True(t, ok)
+	assert.Equal(t, Item[int]{Value: 1, ExpiresAt: item.ExpiresAt, Version: 1}, item)
+
+	time.Sleep(40 * time.Millisecond)
+	item, ok = c.Lookup("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, item.Value)
+	assert.False(t, item.Stale)
+	assert.True(t, item.Refreshing)
+
+	assert.Eventually(t, func() bool {
+		v, _, _ := c.Get("a")
+		return v == 10
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), loads.Load())
+}
+
+// TestCloseStopsRefresh checks that Close cancels the reloads in progress, waits for them
+// and that nothing they load is stored afterwards.
+func TestCloseStopsRefresh(t *testing.T) {
+	started := make(chan struct{})
+	c := New(10, WithLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+		close(started)
+		<-ctx.Done()
+		return 2, time.Hour, nil
+	}), WithStaleGrace[string, int](time.Hour))
+
+	c.Put("a", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	item, _ := c.Lookup("a")
+	assert.True(t, item.Refreshing)
+	<-started
+	assert.NoError(t, c.Close())
+
+	item, ok := c.Lookup("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, item.Value)
+	assert.True(t, item.Stale)
+	assert.False(t, item.Refreshing)
+}
+
+// TestStaleGrace checks that expired entries are served stale while they are reloaded,
+// only until the grace period is over.
+func TestStaleGrace(t *testing.T) {
+	release := make(chan struct{})
+	c := New(10, WithLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+		<-release
+		return 2, time.Hour, nil
+	}), WithStaleGrace[string, int](40*time.Millisecond))
+
+	c.Put("a", 1, 10*time.Millisecond)
+	c.Put("b", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	item, ok := c.Lookup("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, item.Value)
+	assert.True(t, item.Stale)
+	assert.True(t, item.Refreshing)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		v, _, _ := c.Get("a")
+		return v == 2
+	}, time.Second, time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	_, _, ok = c.Get("b")
+	assert.False(t, ok)
+
+	c = New(10, WithStaleGrace[string, int](time.Hour))
+	c.Put("a", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	_, _, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+// TestPutIfAndExpire checks that conditional puts only store when their condition holds,
+// treating expired entries as absent, and that Expire changes the TTL without touching recency.
+func TestPutIfAndExpire(t *testing.T) {
+	c := New[string, int](3)
+	assert.False(t, c.PutIf("a", 1, 0, IfPresent))
+	assert.True(t, c.PutIf("a", 1, 0, IfAbsent))
+	assert.False(t, c.PutIf("a", 2, 0, IfAbsent))
+	assert.True(t, c.PutIf("a", 3, 0, IfPresent))
+	c.Put("b", 4, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	assert.False(t, c.PutIf("b", 5, 0, IfPresent))
+	assert.True(t, c.PutIf("b", 6, 0, IfAbsent))
+
+	assert.True(t, c.Expire("a", time.Millisecond))
+	assert.False(t, c.Expire("missing", time.Hour))
+	keys, _ := c.All()
+	assert.Equal(t, []string{"a", "b"}, keys)
+	assert.True(t, c.Expire("b", time.Hour))
+	assert.True(t, c.Expire("b", 0))
+	_, expiresAt, _ := c.Peek("b")
+	assert.True(t, expiresAt.IsZero())
+
+	time.Sleep(5 * time.Millisecond)
+	assert.False(t, c.Expire("a", time.Hour))
+	_, _, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+// TestBatches verifies that batch operations return their results at the positions of their keys,
+// both within a single cache and across shards, including the values PutMany could not store.
+func TestBatches(t *testing.T) {
+	type batcher interface {
+		PutMany(keys []string, values []int, ttls []time.Duration) []bool
+		GetMany(keys []string) ([]Item[int], []bool)
+		EvictMany(keys []string) ([]int, []bool)
+		Put(key string, value int, ttl time.Duration)
+	}
+	hash := func(key string) uint64 { return uint64(len(key)) }
+	weigher := func(_ string, v int) int64 { return int64(v) }
+	for name, c := range map[string]batcher{
+		"cache":   New(10, WithMaxWeight(30, weigher)),
+		"sharded": NewSharded(10, 3, hash, WithMaxWeight(90, weigher)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			stored := c.PutMany([]string{"a", "bb", "eeeee", "ccc", "a"}, []int{1, 2, 100, 3, 4}, []time.Duration{0, time.Hour, 0, 0, 0})
+			assert.Equal(t, []bool{true, true, false, true, true}, stored)
+			c.Put("dddd", 5, time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+
+			items, ok := c.GetMany([]string{"ccc", "missing", "a", "dddd", "bb"})
+			assert.Equal(t, []bool{true, false, true, false, true}, ok)
+			assert.Equal(t, 3, items[0].Value)
+			assert.Equal(t, 4, items[2].Value)
+			assert.Equal(t, 2, items[4].Value)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), items[4].ExpiresAt, time.Second)
+
+			values, ok := c.EvictMany([]string{"bb", "missing", "a"})
+			assert.Equal(t, []bool{true, false, true}, ok)
+			assert.Equal(t, []int{2, 0, 4}, values)
+			_, ok = c.GetMany([]string{"a", "bb", "ccc"})
+			assert.Equal(t, []bool{false, false, true}, ok)
+		})
+	}
+}
+
+// TestScan verifies that a scan returns every entry once in the requested order, filtered by key,
+// and resumes correctly after the last examined entry was used or removed.
+func TestScan(t *testing.T) {
+	c := New[int, int](100)
+	for i := range 10 {
+		c.Put(i, i, 0)
+	}
+	c.Put(10, 10, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	scan := func(limit int, order Order, match func(int) bool) (keys []int) {
+		var cursor Cursor[int]
+		for {
+			entries, next := c.Scan(cursor, limit, order, match)
+			for _, e := range entries {
+				keys = append(keys, e.Key)
+			}
+			if next == (Cursor[int]{}) {
+				return keys
+			}
+			cursor = next
+		}
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, scan(3, LeastRecent, nil))
+	assert.Equal(t, []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}, scan(4, MostRecent, nil))
+	even := func(key int) bool { return key%2 == 0 }
+	assert.Equal(t, []int{0, 2, 4, 6, 8}, scan(1, LeastRecent, even))
+
+	entries, next := c.Scan(Cursor[int]{}, 3, LeastRecent, nil)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, 2, next.Key)
+	// The last examined entry moves to the end: the scan resumes from its former position.
+	c.Get(2)
+	c.Evict(3)
+	entries, _ = c.Scan(next, 3, LeastRecent, nil)
+	assert.Equal(t, []Entry[int, int]{{Key: 4, Value: 4}, {Key: 5, Value: 5}, {Key: 6, Value: 6}}, entries)
+
+	entries, next = c.Scan(Cursor[int]{}, 2, MostRecent, nil)
+	assert.Equal(t, 2, entries[0].Key)
+	assert.Equal(t, 9, next.Key)
+	c.Evict(9)
+	entries, _ = c.Scan(next, 2, MostRecent, nil)
+	assert.Equal(t, 8, entries[0].Key)
+
+	s := NewSharded[int, int](100, 4, func(key int) uint64 { return uint64(key) })
+	for i := range 20 {
+		s.Put(i, i, 0)
+	}
+	var keys []int
+	var cursor Cursor[int]
+	for {
+		entries, next := s.Scan(cursor, 3, LeastRecent, nil)
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+		if next == (Cursor[int]{}) {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, []int{0, 4, 8, 12, 16, 1, 5, 9, 13, 17, 2, 6, 10, 14, 18, 3, 7, 11, 15, 19}, keys)
+}
+
+// TestPutIfVersion verifies that every stored value gets a new version and that PutIfVersion
+// only replaces the value it was given the version of.
+func TestPutIfVersion(t *testing.T) {
+	c := New[string, int](10)
+	_, stored := c.PutIfVersion("a", 1, 0, 1)
+	assert.False(t, stored)
+
+	c.Put("a", 1, 0)
+	first, ok := c.Lookup("a")
+	assert.True(t, ok)
+	assert.NotZero(t, first.Version)
+	peeked, _ := c.PeekItem("a")
+	assert.Equal(t, first, peeked)
+
+	current, stored := c.PutIfVersion("a", 2, time.Hour, first.Version)
+	assert.True(t, stored)
+	assert.Greater(t, current, first.Version)
+	current2, stored := c.PutIfVersion("a", 3, 0, first.Version)
+	assert.False(t, stored)
+	assert.Equal(t, current, current2)
+	item, _ := c.Lookup("a")
+	assert.Equal(t, 2, item.Value)
+	assert.Equal(t, current, item.Version)
+
+	// Changing the TTL keeps the version, a value stored again after an eviction gets a new one.
+	c.Expire("a", time.Minute)
+	item, _ = c.Lookup("a")
+	assert.Equal(t, current, item.Version)
+	c.Evict("a")
+	c.Put("a", 2, 0)
+	item, _ = c.Lookup("a")
+	assert.Greater(t, item.Version, current)
+}
+
+// TestUpdate checks that Update stores a new entry with the given TTL, keeps the expiration time
+// of a live entry and stores nothing when the update fails.
+func TestUpdate(t *testing.T) {
+	c := New[string, int](2)
+	add := func(delta int) func(int, bool) (int, error) {
+		return func(v int, ok bool) (int, error) {
+			return v + delta, nil
+		}
+	}
+
+	item, err := c.Update("a", time.Hour, add(2))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, item.Value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), item.ExpiresAt, time.Second)
+	expiresAt := item.ExpiresAt
+
+	item, err = c.Update("a", time.Minute, add(3))
+	assert.NoError(t, err)
+	assert.Equal(t, Item[int]{Value: 5, ExpiresAt: expiresAt, Version: item.Version}, item)
+	looked, _ := c.Lookup("a")
+	assert.Equal(t, item, looked)
+
+	_, err = c.Update("a", 0, func(int, bool) (int, error) {
+		return 0, assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	v, _, _ := c.Get("a")
+	assert.Equal(t, 5, v)
+
+	// An updated entry becomes the most recently used one and an expired one starts over.
+	c.Put("b", 1, 10*time.Millisecond)
+	c.Update("a", 0, add(1))
+	c.Put("c", 1, 0)
+	_, _, ok := c.Get("b")
+	assert.False(t, ok)
+	c.Put("b", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	item, _ = c.Update("b", 0, func(v int, ok bool) (int, error) {
+		assert.False(t, ok)
+		return v + 1, nil
+	})
+	assert.Equal(t, Item[int]{Value: 1, Version: item.Version}, item)
+
+	// A value that does not fit is reported instead of returned.
+	w := New(0, WithMaxWeight(10, func(_ string, v int) int64 { return int64(v) }))
+	_, err = w.Update("a", 0, add(20))
+	assert.ErrorIs(t, err, errs.ErrNotStored)
+	_, _, ok = w.Get("a")
+	assert.False(t, ok)
+}
+
+// syncJournal is a journal whose syncs block until released.
+type syncJournal struct {
+	syncing chan struct{}
+	release chan struct{}
+}
+
+func (j *syncJournal) Put(string, int, time.Time) {}
+func (j *syncJournal) Evict(string)               {}
+func (j *syncJournal) EvictAll()                  {}
+
+func (j *syncJournal) Sync() {
+	j.syncing <- struct{}{}
+	<-j.release
+}
+
+// TestSyncJournal verifies that a SyncJournal is synced after the writes that recorded something,
+// before they return but without holding the cache lock, and never after reads.
+func TestSyncJournal(t *testing.T) {
+	j := &syncJournal{syncing: make(chan struct{}), release: make(chan struct{})}
+	c := New(10, WithJournal[string, int](j))
+
+	done := make(chan struct{})
+	go func() {
+		c.Put("a", 1, 0)
+		close(done)
+	}()
+	<-j.syncing
+	c.Get("a")
+	c.Peek("a")
+	select {
+	case <-done:
+		t.Fatal("Put returned before the journal was synced")
+	default:
+	}
+	j.release <- struct{}{}
+	<-done
+
+	evicted := make(chan struct{})
+	go func() {
+		c.Evict("a")
+		close(evicted)
+	}()
+	<-j.syncing
+	j.release <- struct{}{}
+	<-evicted
+}
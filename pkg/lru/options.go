@@ -0,0 +1,4 @@
+package lru
+
+// Option configures optional behaviour of a Cache at construction time.
+type Option[K comparable, V any] func(c *Cache[K, V])
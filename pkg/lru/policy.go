@@ -0,0 +1,196 @@
+package lru
+
+import (
+	"fmt"
+	"lru-cache/pkg/errs"
+	"strings"
+)
+
+// Policy names the algorithm a Cache uses to pick the entry to evict when it is full.
+type Policy string
+
+const (
+	// PolicyLRU evicts the least recently used entry.
+	PolicyLRU Policy = "lru"
+	// PolicyLFU evicts the least frequently used entry, breaking ties by recency.
+	PolicyLFU Policy = "lfu"
+	// PolicySIEVE evicts with the SIEVE algorithm: a FIFO queue scanned by a hand that spares visited entries once.
+	PolicySIEVE Policy = "sieve"
+	// Policy2Q evicts with the 2Q algorithm: new entries go through a FIFO queue and are promoted
+	// to an LRU queue only if they are requested again after leaving it.
+	Policy2Q Policy = "2q"
+	// PolicyARC evicts with the Adaptive Replacement Cache algorithm, which balances recency and frequency
+	// based on hits in the history of recently evicted keys.
+	PolicyARC Policy = "arc"
+)
+
+// ParsePolicy returns the policy with the given case-insensitive name.
+func ParsePolicy(name string) (Policy, error) {
+	p := Policy(strings.ToLower(name))
+	switch p {
+	case PolicyLRU, PolicyLFU, PolicySIEVE, Policy2Q, PolicyARC:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: %q", errs.ErrUnknownPolicy, name)
+}
+
+// WithPolicy selects the eviction policy of the cache. The default is PolicyLRU.
+// Entries are still returned by All in recency order whatever the policy.
+func WithPolicy[K comparable, V any](p Policy) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		switch p {
+		case PolicyLFU:
+			c.policy = newLFU[K, V]()
+		case PolicySIEVE:
+			c.policy = newSIEVE[K, V]()
+		case Policy2Q:
+			c.policy = newTwoQueue[K, V]()
+		case PolicyARC:
+			c.policy = newARC[K, V](c.capacity)
+		default:
+			c.policy = &lruPolicy[K, V]{c: c}
+		}
+	}
+}
+
+// evictor is the bookkeeping of an eviction policy. Its methods are called with the cache lock held.
+type evictor[K comparable, V any] interface {
+	// admit is called when a new entry is stored in the cache.
+	admit(nd *entry[K, V])
+	// access is called when an entry is read.
+	access(nd *entry[K, V])
+	// victim returns the entry to evict to make room for the candidate key, or nil if there is none.
+	victim(candidate K) *entry[K, V]
+	// remove is called when an entry leaves the cache. evicted is true if it was picked by victim.
+	remove(nd *entry[K, V], evicted bool)
+	// reset forgets every entry.
+	reset()
+}
+
+// lruPolicy evicts from the front of the recency list the cache maintains anyway.
+type lruPolicy[K comparable, V any] struct {
+	c *Cache[K, V]
+}
+
+func (p *lruPolicy[K, V]) admit(nd *entry[K, V])  {}
+func (p *lruPolicy[K, V]) access(nd *entry[K, V]) {}
+
+func (p *lruPolicy[K, V]) victim(K) *entry[K, V] {
+	if nd := p.c.left.next; nd != p.c.right {
+		return nd
+	}
+	return nil
+}
+
+func (p *lruPolicy[K, V]) remove(nd *entry[K, V], evicted bool) {}
+func (p *lruPolicy[K, V]) reset()                               {}
+
+// queue is an intrusive doubly-linked list of entries, ordered from front to back,
+// used by the policies to keep their own order independent from the recency list.
+type queue[K comparable, V any] struct {
+	root entry[K, V]
+	len  int
+}
+
+func newQueue[K comparable, V any]() *queue[K, V] {
+	q := &queue[K, V]{}
+	q.root.pprev, q.root.pnext = &q.root, &q.root
+	return q
+}
+
+func (q *queue[K, V]) front() *entry[K, V] {
+	if q.len == 0 {
+		return nil
+	}
+	return q.root.pnext
+}
+
+// next returns the entry after nd, or nil if nd is the last one.
+func (q *queue[K, V]) next(nd *entry[K, V]) *entry[K, V] {
+	if nd.pnext == &q.root {
+		return nil
+	}
+	return nd.pnext
+}
+
+func (q *queue[K, V]) pushBack(nd *entry[K, V]) {
+	nd.pprev, nd.pnext = q.root.pprev, &q.root
+	q.root.pprev.pnext = nd
+	q.root.pprev = nd
+	q.len++
+}
+
+func (q *queue[K, V]) remove(nd *entry[K, V]) {
+	nd.pprev.pnext, nd.pnext.pprev = nd.pnext, nd.pprev
+	nd.pprev, nd.pnext = nil, nil
+	q.len--
+}
+
+func (q *queue[K, V]) moveToBack(nd *entry[K, V]) {
+	q.remove(nd)
+	q.pushBack(nd)
+}
+
+func (q *queue[K, V]) reset() {
+	q.root.pprev, q.root.pnext = &q.root, &q.root
+	q.len = 0
+}
+
+// ghosts is a FIFO of recently evicted keys, used by 2Q and ARC to recognise returning keys.
+type ghosts[K comparable] struct {
+	root ghost[K]
+	keys map[K]*ghost[K]
+}
+
+type ghost[K comparable] struct {
+	key  K
+	prev *ghost[K]
+	next *ghost[K]
+}
+
+func newGhosts[K comparable]() *ghosts[K] {
+	g := &ghosts[K]{keys: make(map[K]*ghost[K])}
+	g.root.prev, g.root.next = &g.root, &g.root
+	return g
+}
+
+func (g *ghosts[K]) len() int {
+	return len(g.keys)
+}
+
+func (g *ghosts[K]) contains(key K) bool {
+	_, ok := g.keys[key]
+	return ok
+}
+
+// push remembers the key as the newest one.
+func (g *ghosts[K]) push(key K) {
+	if _, ok := g.keys[key]; ok {
+		return
+	}
+	gh := &ghost[K]{key: key, prev: g.root.prev, next: &g.root}
+	g.root.prev.next = gh
+	g.root.prev = gh
+	g.keys[key] = gh
+}
+
+func (g *ghosts[K]) remove(key K) {
+	gh, ok := g.keys[key]
+	if !ok {
+		return
+	}
+	gh.prev.next, gh.next.prev = gh.next, gh.prev
+	delete(g.keys, key)
+}
+
+// pop forgets the oldest key.
+func (g *ghosts[K]) pop() {
+	if len(g.keys) > 0 {
+		g.remove(g.root.next.key)
+	}
+}
+
+func (g *ghosts[K]) reset() {
+	g.root.prev, g.root.next = &g.root, &g.root
+	clear(g.keys)
+}
@@ -0,0 +1,139 @@
+package lru
+
+import (
+	"context"
+	"time"
+)
+
+// Item is an entry returned by Lookup along with the state of its TTL.
+type Item[V any] struct {
+	Value V
+	// ExpiresAt is the expiration time of the entry, zero if it never expires.
+	ExpiresAt time.Time
+	// Stale reports that the entry has expired and is served during the grace period set with WithStaleGrace.
+	Stale bool
+	// Refreshing reports that the loader registered with WithLoader is reloading the entry.
+	Refreshing bool
+	// Version identifies the value of the entry: it changes every time a value is stored under the key,
+	// increasing within a cache, or a shard, and is never 0. See PutIfVersion.
+	Version uint64
+}
+
+// WithLoader registers the loader used to reload entries in the background for WithRefreshAhead and WithStaleGrace.
+// It is called in its own goroutine, at most once at a time per entry, with a context canceled by Close.
+// If it fails, the entry is left as it was and the next read tries again. Reads of a closed cache trigger no reload.
+func WithLoader[K comparable, V any](load func(ctx context.Context, key K) (V, time.Duration, error)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.loader = load
+	}
+}
+
+// WithRefreshAhead makes a read of an entry within the last fraction of its TTL trigger an asynchronous reload
+// through the loader registered with WithLoader, while the current value is returned right away.
+// For example, with 0.2 an entry stored for a minute is refreshed by reads in its last 12 seconds.
+// A fraction outside of (0, 1] disables it.
+func WithRefreshAhead[K comparable, V any](fraction float64) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		if fraction <= 0 || fraction > 1 {
+			return
+		}
+		c.refreshAhead = fraction
+	}
+}
+
+// WithStaleGrace keeps expired entries for the grace period after their TTL, during which reads return
+// the stale value and trigger an asynchronous reload through the loader registered with WithLoader.
+// Background expiration removes entries only once their grace period is over.
+// It has no effect without a loader.
+func WithStaleGrace[K comparable, V any](grace time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.grace = max(grace, 0)
+	}
+}
+
+// Lookup retrieves the entry stored under key like Get, also telling whether it is stale or being refreshed.
+// With WithRefreshAhead or WithStaleGrace, it triggers a reload of the entry when it is due.
+func (c *Cache[K, V]) Lookup(key K) (item Item[V], ok bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.lookup(key)
+}
+
+// Lookup retrieves the entry stored under key from the shard of the key. See Cache.Lookup.
+func (s *Sharded[K, V]) Lookup(key K) (item Item[V], ok bool) {
+	return s.shard(key).Lookup(key)
+}
+
+func (c *Cache[K, V]) lookup(key K) (item Item[V], ok bool) {
+	if c.admission != nil {
+		c.admission.record(key)
+	}
+	nd, ok := c.data[key]
+	if !ok {
+		c.counters.misses.Add(1)
+		return item, false
+	}
+	now := time.Now()
+	stale := nd.expired(now)
+	if stale && !nd.expiresAt.Add(c.grace).After(now) {
+		c.drop(nd, ReasonExpired)
+		c.counters.misses.Add(1)
+		return item, false
+	}
+	c.counters.hits.Add(1)
+	c.remove(nd)
+	c.insert(nd)
+	c.policy.access(nd)
+	if c.accesses != nil {
+		c.accesses.Access(key)
+	}
+	if c.loader != nil && !nd.refreshing && (stale || c.refreshDue(nd, now)) {
+		c.refresh(nd)
+	}
+	return Item[V]{Value: nd.value, ExpiresAt: nd.expiresAt, Stale: stale, Refreshing: nd.refreshing, Version: nd.version}, true
+}
+
+// refreshDue reports whether the entry is within the refresh-ahead window at the end of its TTL.
+func (c *Cache[K, V]) refreshDue(nd *entry[K, V], now time.Time) bool {
+	if c.refreshAhead == 0 || nd.expiresAt.IsZero() {
+		return false
+	}
+	return nd.expiresAt.Sub(now) < time.Duration(c.refreshAhead*float64(nd.ttl))
+}
+
+// refresh reloads the entry in the background. The reloaded value replaces the entry only if it is still
+// in the cache by then, so that entries removed or overwritten during the reload are not brought back.
+// Reloads are tracked like the other background goroutines, so Close waits for them
+// and nothing is stored once the cache is closed.
+func (c *Cache[K, V]) refresh(nd *entry[K, V]) {
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+	nd.refreshing = true
+	version := nd.version
+	c.done.Add(1)
+	go func() {
+		defer c.done.Done()
+		value, ttl, err := c.loader(c.ctx, nd.key)
+
+		c.mu.Lock()
+		defer c.unlock()
+
+		if c.data[nd.key] != nd || nd.version != version {
+			return
+		}
+		select {
+		case <-c.stop:
+			err = context.Canceled
+		default:
+		}
+		if err != nil {
+			nd.refreshing = false
+			return
+		}
+		c.put(nd.key, value, ttl)
+	}()
+}
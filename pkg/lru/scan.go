@@ -0,0 +1,112 @@
+package lru
+
+import "time"
+
+// Order is the order in which Scan walks the entries of a cache.
+type Order int
+
+const (
+	// LeastRecent walks the entries from the least to the most recently used one, like All.
+	LeastRecent Order = iota
+	// MostRecent walks the entries from the most to the least recently used one.
+	MostRecent
+)
+
+// scanWork bounds the number of entries a page of Scan examines, as a multiple of its limit,
+// so that a filter matching few keys does not hold the lock over the whole cache.
+const scanWork = 10
+
+// Cursor is the position of a scan. The zero Cursor starts a new scan.
+type Cursor[K comparable] struct {
+	// Shard is the shard of a Sharded cache the scan is in, always 0 for a Cache.
+	Shard int
+	// Seq is the position of the last entry examined in the list of the shard.
+	Seq uint64
+	// Key is the key of the last entry examined, to resume right after it while it has not moved.
+	Key K
+}
+
+// Scan returns a page of at most limit live entries whose key satisfies match, nil matching every key,
+// starting after cursor and walking the entries in the given order. It also returns the cursor
+// of the next page, which is the zero Cursor once the scan is complete.
+//
+// The lock is only held while a page is built and a page examines at most ten times limit entries,
+// so a page may hold fewer entries than limit, even none, before the scan is complete.
+// Entries present during the whole scan and not used in the meantime are returned exactly once.
+// In LeastRecent order, an entry used during the scan moves ahead of it and may be returned again;
+// in MostRecent order, it moves behind it and is missed if it was not returned yet.
+func (c *Cache[K, V]) Scan(cursor Cursor[K], limit int, order Order, match func(K) bool) (entries []Entry[K, V], next Cursor[K]) {
+	limit = max(limit, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	nd := c.seek(cursor, order)
+	for work := 0; nd != c.left && nd != c.right; work++ {
+		if len(entries) == limit || work == limit*scanWork {
+			last := nd.prev
+			if order == MostRecent {
+				last = nd.next
+			}
+			return entries, Cursor[K]{Shard: cursor.Shard, Seq: last.seq, Key: last.key}
+		}
+		if !nd.expired(now) && (match == nil || match(nd.key)) {
+			entries = append(entries, Entry[K, V]{Key: nd.key, Value: nd.value, ExpiresAt: nd.expiresAt})
+		}
+		if order == MostRecent {
+			nd = nd.prev
+		} else {
+			nd = nd.next
+		}
+	}
+	return entries, Cursor[K]{}
+}
+
+// seek returns the first entry to examine after cursor in the given order, or a sentinel if there is none.
+// It resumes right after the last examined entry if it has not moved since, otherwise it looks
+// for the first entry past its position from the end of the list.
+func (c *Cache[K, V]) seek(cursor Cursor[K], order Order) *entry[K, V] {
+	if order == MostRecent {
+		if cursor.Seq == 0 {
+			return c.right.prev
+		}
+		if nd, ok := c.data[cursor.Key]; ok && nd.seq == cursor.Seq {
+			return nd.prev
+		}
+		nd := c.left.next
+		for nd != c.right && nd.seq < cursor.Seq {
+			nd = nd.next
+		}
+		return nd.prev
+	}
+	if cursor.Seq == 0 {
+		return c.left.next
+	}
+	if nd, ok := c.data[cursor.Key]; ok && nd.seq == cursor.Seq {
+		return nd.next
+	}
+	nd := c.right.prev
+	for nd != c.left && nd.seq > cursor.Seq {
+		nd = nd.prev
+	}
+	return nd.next
+}
+
+// Scan returns a page of entries like Cache.Scan, walking the shards one after another,
+// each in the given order. Recency is thus only ordered within a shard.
+func (s *Sharded[K, V]) Scan(cursor Cursor[K], limit int, order Order, match func(K) bool) (entries []Entry[K, V], next Cursor[K]) {
+	limit = max(limit, 1)
+	for shard := cursor.Shard; shard < len(s.shards); shard++ {
+		page, next := s.shards[shard].Scan(cursor, limit-len(entries), order, match)
+		entries = append(entries, page...)
+		if next != (Cursor[K]{}) {
+			next.Shard = shard
+			return entries, next
+		}
+		cursor = Cursor[K]{}
+		if len(entries) == limit && shard+1 < len(s.shards) {
+			return entries, Cursor[K]{Shard: shard + 1}
+		}
+	}
+	return entries, Cursor[K]{}
+}
@@ -0,0 +1,130 @@
+package lru
+
+import "time"
+
+// Sharded is a concurrency-safe LRU cache split into independent segments, each with its own lock
+// and a slice of the total capacity. A key always lives in the segment selected by its hash,
+// so operations on different segments never contend. Recency is tracked per segment,
+// which makes eviction approximately, rather than strictly, least recently used.
+type Sharded[K comparable, V any] struct {
+	shards []*Cache[K, V]
+	hash   func(K) uint64
+}
+
+// NewSharded creates a cache of the specified total capacity split into the given number of shards,
+// using hash to pick the shard of a key. The options are applied to every shard.
+// The number of shards is clamped to [1, capacity] when the capacity is positive.
+// A weight limit set with WithMaxWeight is split between the shards like the capacity.
+func NewSharded[K comparable, V any](capacity, shards int, hash func(K) uint64, opts ...Option[K, V]) *Sharded[K, V] {
+	if capacity > 0 {
+		shards = min(shards, capacity)
+	}
+	shards = max(1, shards)
+	s := &Sharded[K, V]{
+		shards: make([]*Cache[K, V], shards),
+		hash:   hash,
+	}
+	for i := range s.shards {
+		size := capacity / shards
+		if i < capacity%shards {
+			size++
+		}
+		s.shards[i] = New(size, append(opts[:len(opts):len(opts)], shareWeight[K, V](i, shards))...)
+	}
+	return s
+}
+
+// shareWeight gives shard i its share of the weight limit set by the options before it,
+// so that New sizes what depends on the limit, such as the admission sketch, for the shard.
+func shareWeight[K comparable, V any](i, shards int) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		if c.maxWeight > 0 {
+			share := c.maxWeight / int64(shards)
+			if int64(i) < c.maxWeight%int64(shards) {
+				share++
+			}
+			c.maxWeight = share
+		}
+	}
+}
+
+func (s *Sharded[K, V]) shard(key K) *Cache[K, V] {
+	return s.shards[s.hash(key)%uint64(len(s.shards))]
+}
+
+// Close stops the background goroutines of every shard.
+func (s *Sharded[K, V]) Close() error {
+	for _, c := range s.shards {
+		c.Close()
+	}
+	return nil
+}
+
+// Put stores value under key with the specified TTL in the shard of the key. See Cache.Put.
+func (s *Sharded[K, V]) Put(key K, value V, ttl time.Duration) {
+	s.shard(key).Put(key, value, ttl)
+}
+
+// Get retrieves the value stored under key from the shard of the key. See Cache.Get.
+func (s *Sharded[K, V]) Get(key K) (value V, expiresAt time.Time, ok bool) {
+	return s.shard(key).Get(key)
+}
+
+// Peek retrieves the value stored under key from the shard of the key. See Cache.Peek.
+func (s *Sharded[K, V]) Peek(key K) (value V, expiresAt time.Time, ok bool) {
+	return s.shard(key).Peek(key)
+}
+
+// All returns the contents of every shard, one after another, each ordered from the least to the most recently used entry.
+func (s *Sharded[K, V]) All() (keys []K, values []V) {
+	for _, c := range s.shards {
+		k, v := c.All()
+		keys = append(keys, k...)
+		values = append(values, v...)
+	}
+	return keys, values
+}
+
+// Evict removes the entry stored under key from the shard of the key. See Cache.Evict.
+func (s *Sharded[K, V]) Evict(key K) (value V, ok bool) {
+	return s.shard(key).Evict(key)
+}
+
+// EvictAll removes every entry from every shard. All shards are locked at once,
+// so that a journal records the removal as a single step.
+func (s *Sharded[K, V]) EvictAll() {
+	for _, c := range s.shards {
+		c.mu.Lock()
+	}
+	for _, c := range s.shards {
+		c.clear()
+	}
+	if j := s.shards[0].journal; j != nil {
+		j.EvictAll()
+	}
+	deliveries := make([]func(), len(s.shards))
+	for i, c := range s.shards {
+		deliveries[i] = c.release()
+	}
+	for _, deliver := range deliveries {
+		deliver()
+	}
+}
+
+// Len returns the number of entries stored across all shards.
+func (s *Sharded[K, V]) Len() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// Cap returns the total capacity of all shards.
+func (s *Sharded[K, V]) Cap() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Cap()
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package lru
+
+// sieve implements SIEVE: entries sit in a FIFO queue and are only flagged as visited on access.
+// A hand sweeps the queue from the oldest entry, clearing the flag of visited entries and
+// evicting the first unvisited one, then stays where it stopped for the next eviction.
+type sieve[K comparable, V any] struct {
+	q    *queue[K, V]
+	hand *entry[K, V]
+}
+
+func newSIEVE[K comparable, V any]() *sieve[K, V] {
+	return &sieve[K, V]{q: newQueue[K, V]()}
+}
+
+func (p *sieve[K, V]) admit(nd *entry[K, V]) {
+	nd.visited = false
+	p.q.pushBack(nd)
+}
+
+func (p *sieve[K, V]) access(nd *entry[K, V]) {
+	nd.visited = true
+}
+
+func (p *sieve[K, V]) victim(K) *entry[K, V] {
+	nd := p.hand
+	if nd == nil {
+		nd = p.q.front()
+	}
+	for nd != nil && nd.visited {
+		nd.visited = false
+		if nd = p.q.next(nd); nd == nil {
+			nd = p.q.front()
+		}
+	}
+	p.hand = nd
+	return nd
+}
+
+func (p *sieve[K, V]) remove(nd *entry[K, V], evicted bool) {
+	if p.hand == nd {
+		p.hand = p.q.next(nd)
+	}
+	p.q.remove(nd)
+}
+
+func (p *sieve[K, V]) reset() {
+	p.q.reset()
+	p.hand = nil
+}
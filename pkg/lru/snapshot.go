@@ -0,0 +1,66 @@
+package lru
+
+import "time"
+
+// Entry is a key-value pair of a cache with its expiration time, zero if it never expires.
+type Entry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	ExpiresAt time.Time
+}
+
+// Snapshot returns the live entries of the cache ordered from the least to the most recently used one,
+// so that Restore can rebuild the same cache.
+func (c *Cache[K, V]) Snapshot() []Entry[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]Entry[K, V], 0, len(c.data))
+	for nd := c.left.next; nd != c.right; nd = nd.next {
+		if !nd.expired(now) {
+			entries = append(entries, Entry[K, V]{Key: nd.key, Value: nd.value, ExpiresAt: nd.expiresAt})
+		}
+	}
+	return entries
+}
+
+// Restore stores the entries, ordered from the least to the most recently used one,
+// keeping their expiration times. Entries that have already expired are skipped.
+func (c *Cache[K, V]) Restore(entries []Entry[K, V]) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	for _, e := range entries {
+		if ttl, ok := remaining(e.ExpiresAt); ok {
+			c.put(e.Key, e.Value, ttl)
+		}
+	}
+}
+
+// Snapshot returns the live entries of every shard, one shard after another. See Cache.Snapshot.
+func (s *Sharded[K, V]) Snapshot() []Entry[K, V] {
+	var entries []Entry[K, V]
+	for _, c := range s.shards {
+		entries = append(entries, c.Snapshot()...)
+	}
+	return entries
+}
+
+// Restore stores the entries in the shards of their keys. See Cache.Restore.
+func (s *Sharded[K, V]) Restore(entries []Entry[K, V]) {
+	for _, e := range entries {
+		if ttl, ok := remaining(e.ExpiresAt); ok {
+			s.shard(e.Key).Put(e.Key, e.Value, ttl)
+		}
+	}
+}
+
+// remaining returns the TTL left until expiresAt, zero if it is zero, and false if it has passed.
+func remaining(expiresAt time.Time) (time.Duration, bool) {
+	if expiresAt.IsZero() {
+		return 0, true
+	}
+	ttl := time.Until(expiresAt)
+	return ttl, ttl > 0
+}
@@ -0,0 +1,111 @@
+package lru
+
+import "sync/atomic"
+
+// Stats is a point-in-time snapshot of the counters of a cache.
+type Stats struct {
+	// Hits is the number of reads that found a live entry.
+	Hits uint64
+	// Misses is the number of reads that found no entry or an expired one.
+	Misses uint64
+	// Puts is the number of writes.
+	Puts uint64
+	// Evictions is the number of entries evicted by the eviction policy to make room for others.
+	Evictions uint64
+	// Expirations is the number of entries removed because their TTL had passed.
+	Expirations uint64
+	// Removals is the number of entries removed explicitly with Evict or EvictAll.
+	Removals uint64
+	// Admitted is the number of new keys the admission filter let in over an evicted entry.
+	Admitted uint64
+	// Rejected is the number of new keys the admission filter refused to store.
+	Rejected uint64
+	// Size is the number of entries currently stored.
+	Size int
+	// Capacity is the maximum number of entries, not positive if the cache is only bounded by weight.
+	Capacity int
+	// Weight is the total weight of the stored entries, zero unless WithMaxWeight is used.
+	Weight int64
+	// MaxWeight is the weight the cache is bounded by, zero unless WithMaxWeight is used.
+	MaxWeight int64
+}
+
+// HitRatio returns the share of reads that were hits, or zero if there were no reads.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// add returns the sum of both snapshots, used to aggregate shards.
+func (s Stats) add(o Stats) Stats {
+	return Stats{
+		Hits:        s.Hits + o.Hits,
+		Misses:      s.Misses + o.Misses,
+		Puts:        s.Puts + o.Puts,
+		Evictions:   s.Evictions + o.Evictions,
+		Expirations: s.Expirations + o.Expirations,
+		Removals:    s.Removals + o.Removals,
+		Admitted:    s.Admitted + o.Admitted,
+		Rejected:    s.Rejected + o.Rejected,
+		Size:        s.Size + o.Size,
+		Capacity:    s.Capacity + o.Capacity,
+		Weight:      s.Weight + o.Weight,
+		MaxWeight:   s.MaxWeight + o.MaxWeight,
+	}
+}
+
+// counters are updated atomically so that reading them never waits for the cache lock.
+type counters struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	puts        atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+	removals    atomic.Uint64
+	admitted    atomic.Uint64
+	rejected    atomic.Uint64
+	size        atomic.Int64
+	weight      atomic.Int64
+}
+
+// count records the removal of an entry for the given reason.
+func (c *counters) count(reason EvictReason) {
+	switch reason {
+	case ReasonCapacity:
+		c.evictions.Add(1)
+	case ReasonExpired:
+		c.expirations.Add(1)
+	case ReasonExplicit, ReasonCleared:
+		c.removals.Add(1)
+	}
+}
+
+// Stats returns a snapshot of the counters of the cache.
+// It does not take the cache lock, so the counters may be slightly out of sync with each other.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        c.counters.hits.Load(),
+		Misses:      c.counters.misses.Load(),
+		Puts:        c.counters.puts.Load(),
+		Evictions:   c.counters.evictions.Load(),
+		Expirations: c.counters.expirations.Load(),
+		Removals:    c.counters.removals.Load(),
+		Admitted:    c.counters.admitted.Load(),
+		Rejected:    c.counters.rejected.Load(),
+		Size:        int(c.counters.size.Load()),
+		Capacity:    c.capacity,
+		Weight:      c.counters.weight.Load(),
+		MaxWeight:   c.maxWeight,
+	}
+}
+
+// Stats returns the sum of the counters of every shard.
+func (s *Sharded[K, V]) Stats() Stats {
+	var st Stats
+	for _, c := range s.shards {
+		st = st.add(c.Stats())
+	}
+	return st
+}
@@ -0,0 +1,69 @@
+package lru
+
+const (
+	queueIn uint8 = iota + 1
+	queueMain
+)
+
+// twoQueue implements the full 2Q algorithm. New entries enter the in FIFO, which holds
+// about a quarter of the cache, so a scan only flushes that queue. Keys evicted from it are
+// remembered in the out ghost queue, and come back straight into the main LRU queue
+// when they are stored again, as they have proven to be reused.
+type twoQueue[K comparable, V any] struct {
+	in   *queue[K, V]
+	main *queue[K, V]
+	out  *ghosts[K]
+}
+
+func newTwoQueue[K comparable, V any]() *twoQueue[K, V] {
+	return &twoQueue[K, V]{
+		in:   newQueue[K, V](),
+		main: newQueue[K, V](),
+		out:  newGhosts[K](),
+	}
+}
+
+func (p *twoQueue[K, V]) admit(nd *entry[K, V]) {
+	if p.out.contains(nd.key) {
+		p.out.remove(nd.key)
+		nd.queue = queueMain
+		p.main.pushBack(nd)
+		return
+	}
+	nd.queue = queueIn
+	p.in.pushBack(nd)
+}
+
+func (p *twoQueue[K, V]) access(nd *entry[K, V]) {
+	if nd.queue == queueMain {
+		p.main.moveToBack(nd)
+	}
+}
+
+func (p *twoQueue[K, V]) victim(K) *entry[K, V] {
+	resident := p.in.len + p.main.len
+	if p.in.len > max(1, resident/4) || p.main.len == 0 {
+		return p.in.front()
+	}
+	return p.main.front()
+}
+
+func (p *twoQueue[K, V]) remove(nd *entry[K, V], evicted bool) {
+	if nd.queue == queueMain {
+		p.main.remove(nd)
+		return
+	}
+	p.in.remove(nd)
+	if evicted {
+		p.out.push(nd.key)
+		for p.out.len() > max(1, (p.in.len+p.main.len)/2) {
+			p.out.pop()
+		}
+	}
+}
+
+func (p *twoQueue[K, V]) reset() {
+	p.in.reset()
+	p.main.reset()
+	p.out.reset()
+}
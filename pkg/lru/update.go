@@ -0,0 +1,48 @@
+package lru
+
+import (
+	"lru-cache/pkg/errs"
+	"time"
+)
+
+// Update atomically replaces the value stored under key with the one computed by fn from the current value,
+// ok telling whether key has a live entry. A new entry is stored with ttl, while a live entry keeps
+// its expiration time and becomes the most recently used one. fn is called under the lock of the cache
+// and must not use it. If fn returns an error, nothing is stored and the error is returned.
+// Returns the stored item, or errs.ErrNotStored if the new value was not admitted, in which case
+// the previous entry is removed as with Put.
+func (c *Cache[K, V]) Update(key K, ttl time.Duration, fn func(value V, ok bool) (V, error)) (Item[V], error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var current V
+	old, replacing := c.data[key]
+	live := replacing && !old.expired(time.Now())
+	if live {
+		current = old.value
+	}
+	value, err := fn(current, live)
+	if err != nil {
+		return Item[V]{}, err
+	}
+	var expiresAt time.Time
+	if live {
+		expiresAt, ttl = old.expiresAt, old.ttl
+	}
+	nd := c.set(key, value, ttl)
+	if nd != nil && live {
+		c.unindex(nd)
+		nd.expiresAt = expiresAt
+		c.index(nd)
+	}
+	c.record(key, nd, replacing)
+	if nd == nil {
+		return Item[V]{}, errs.ErrNotStored
+	}
+	return Item[V]{Value: nd.value, ExpiresAt: nd.expiresAt, Version: nd.version}, nil
+}
+
+// Update atomically replaces the value stored under key in the shard of the key. See Cache.Update.
+func (s *Sharded[K, V]) Update(key K, ttl time.Duration, fn func(value V, ok bool) (V, error)) (Item[V], error) {
+	return s.shard(key).Update(key, ttl, fn)
+}
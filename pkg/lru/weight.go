@@ -0,0 +1,23 @@
+package lru
+
+// WithMaxWeight bounds the cache by the total weight of its entries instead of their number:
+// weigher computes the cost of every stored entry, and Put evicts entries until the new one fits in max.
+// The entry count limit still applies if the capacity passed to New is positive.
+// An entry heavier than max is never stored.
+func WithMaxWeight[K comparable, V any](max int64, weigher func(key K, value V) int64) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		if max <= 0 {
+			return
+		}
+		c.maxWeight = max
+		c.weigher = weigher
+	}
+}
+
+// full reports whether an entry of the given weight cannot be added without evicting another one first.
+func (c *Cache[K, V]) full(weight int64) bool {
+	if c.maxWeight > 0 {
+		return c.counters.weight.Load()+weight > c.maxWeight || (c.capacity > 0 && len(c.data) >= c.capacity)
+	}
+	return len(c.data) >= c.capacity
+}
@@ -0,0 +1,167 @@
+package lru
+
+import "time"
+
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelMask   = wheelSlots - 1
+	wheelLevels = 4
+)
+
+// wheel is a hierarchical timing wheel indexing entries by expiration time.
+// Level 0 has one slot per tick, each next level has slots 64 times wider. Entries are linked
+// into the slot of their expiration tick and cascade down to finer levels as time advances,
+// so scheduling, unscheduling and expiring an entry are all O(1) amortized.
+// Entries expiring further than the whole wheel spans are parked in an overflow list
+// which is rescheduled every time the top level wraps around.
+type wheel[K comparable, V any] struct {
+	tick     time.Duration
+	origin   time.Time
+	current  uint64
+	slots    [wheelLevels][wheelSlots]*entry[K, V]
+	overflow *entry[K, V]
+	// grace delays the expiration of entries, see WithStaleGrace.
+	grace time.Duration
+}
+
+func newWheel[K comparable, V any](tick time.Duration) *wheel[K, V] {
+	w := &wheel[K, V]{tick: tick, origin: time.Now()}
+	for l := range w.slots {
+		for s := range w.slots[l] {
+			w.slots[l][s] = newBucket[K, V]()
+		}
+	}
+	w.overflow = newBucket[K, V]()
+	return w
+}
+
+// newBucket returns a sentinel of an empty circular list of entries.
+func newBucket[K comparable, V any]() *entry[K, V] {
+	b := &entry[K, V]{}
+	b.wprev, b.wnext = b, b
+	return b
+}
+
+// WithTimingWheel indexes entries with a TTL in a hierarchical timing wheel and removes them
+// in the background once they expire, with a precision of one tick. A non-positive tick disables it.
+func WithTimingWheel[K comparable, V any](tick time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		if tick <= 0 {
+			return
+		}
+		c.wheel = newWheel[K, V](tick)
+	}
+}
+
+// at returns the first tick at which the given time has passed.
+func (w *wheel[K, V]) at(t time.Time) uint64 {
+	d := t.Sub(w.origin)
+	if d <= 0 {
+		return 0
+	}
+	return uint64((d + w.tick - 1) / w.tick)
+}
+
+// schedule links the entry into the slot of its expiration tick.
+// Entries that are already due go to the next tick, as the current one has been processed.
+func (w *wheel[K, V]) schedule(nd *entry[K, V]) {
+	w.link(nd, max(w.at(nd.expiresAt.Add(w.grace)), w.current+1))
+}
+
+func (w *wheel[K, V]) link(nd *entry[K, V], t uint64) {
+	delta := t - w.current
+
+	bucket := w.overflow
+	for l := range wheelLevels {
+		if delta < 1<<(wheelBits*(l+1)) {
+			bucket = w.slots[l][(t>>(wheelBits*l))&wheelMask]
+			break
+		}
+	}
+
+	nd.wprev, nd.wnext = bucket.wprev, bucket
+	bucket.wprev.wnext = nd
+	bucket.wprev = nd
+}
+
+// unschedule unlinks the entry from its slot if it is scheduled.
+func (w *wheel[K, V]) unschedule(nd *entry[K, V]) {
+	if nd.wnext == nil {
+		return
+	}
+	nd.wprev.wnext, nd.wnext.wprev = nd.wnext, nd.wprev
+	nd.wprev, nd.wnext = nil, nil
+}
+
+// advance moves the wheel up to now, calling expire for every entry whose expiration tick has passed.
+func (w *wheel[K, V]) advance(now time.Time, expire func(nd *entry[K, V])) {
+	target := uint64(now.Sub(w.origin) / w.tick)
+	for w.current < target {
+		w.current++
+		w.cascade()
+		bucket := w.slots[0][w.current&wheelMask]
+		for nd := bucket.wnext; nd != bucket; nd = bucket.wnext {
+			w.unschedule(nd)
+			expire(nd)
+		}
+	}
+}
+
+// cascade moves the entries of the coarser slots that start at the current tick down to finer levels.
+// Coarser levels go first so that their entries can land in the finer slots cascaded right after.
+func (w *wheel[K, V]) cascade() {
+	top := 0
+	for top < wheelLevels && w.current&(1<<(wheelBits*(top+1))-1) == 0 {
+		top++
+	}
+	if top == wheelLevels {
+		w.reschedule(w.overflow)
+		top--
+	}
+	for l := top; l >= 1; l-- {
+		w.reschedule(w.slots[l][(w.current>>(wheelBits*l))&wheelMask])
+	}
+}
+
+// reschedule relinks the entries of a slot that is being cascaded. Unlike schedule, it links
+// entries due at the current tick into the current slot, which is processed right after cascading.
+func (w *wheel[K, V]) reschedule(bucket *entry[K, V]) {
+	nd := bucket.wnext
+	bucket.wprev, bucket.wnext = bucket, bucket
+	for nd != bucket {
+		next := nd.wnext
+		w.link(nd, max(w.at(nd.expiresAt.Add(w.grace)), w.current))
+		nd = next
+	}
+}
+
+// reset unschedules every entry at once.
+func (w *wheel[K, V]) reset() {
+	for l := range w.slots {
+		for _, bucket := range w.slots[l] {
+			bucket.wprev, bucket.wnext = bucket, bucket
+		}
+	}
+	w.overflow.wprev, w.overflow.wnext = w.overflow, w.overflow
+}
+
+func (c *Cache[K, V]) wheelLoop() {
+	defer c.done.Done()
+
+	ticker := time.NewTicker(c.wheel.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			c.wheel.advance(now, func(nd *entry[K, V]) {
+				c.drop(nd, ReasonExpired)
+			})
+			c.unlock()
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package lrupb holds the gRPC service definition of the cache and the code generated from it.
+package lrupb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative lru.proto